@@ -62,7 +62,7 @@ func (a *AuthObject) IsSessionExpired(r *http.Request) (bool, error) {
 					}
 				}
 
-				user_rr, err := a.Model.GetRecords(f, 1)
+				user_rr, err := a.Model.GetRecordsContext(r.Context(), f, 1)
 				if err != nil {
 					// Return [true] + error
 					return true, err
@@ -87,7 +87,7 @@ func (a *AuthObject) IsSessionExpired(r *http.Request) (bool, error) {
 					// Update idle value, session is not expired, user is still authenticated
 					fld := make([]SQLField, 0)
 					fld = append(fld, SQLField{FieldName: a.ExpTimeFieldName, Value: a.GetExpirationFromNow()})
-					a.Model.Update(fld, fmt.Sprint(userId))
+					a.Model.UpdateContext(r.Context(), fld, fmt.Sprint(userId))
 					return false, nil
 				} else {
 					InfoMessage("User not found in database, cookie value not match")
@@ -133,7 +133,7 @@ func (a *AuthObject) KillAuthSession(w http.ResponseWriter, r *http.Request) err
 				token := Session.Get(r.Context(), a.SessionKey).(string)
 				f := make([]Filter, 0)
 				f = append(f, Filter{Field: a.HashCodeFieldName, Operator: "=", Value: token})
-				user_rr, err := a.Model.GetRecords(f, 1)
+				user_rr, err := a.Model.GetRecordsContext(r.Context(), f, 1)
 				if err != nil {
 					// Return error
 					return err
@@ -144,7 +144,7 @@ func (a *AuthObject) KillAuthSession(w http.ResponseWriter, r *http.Request) err
 
 				fld := make([]SQLField, 0)
 				fld = append(fld, SQLField{FieldName: a.ExpTimeFieldName, Value: t1})
-				a.Model.Update(fld, fmt.Sprint(userId))
+				a.Model.UpdateContext(r.Context(), fld, fmt.Sprint(userId))
 			}
 			Session.Destroy(r.Context())
 			return nil
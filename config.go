@@ -6,6 +6,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // AppConfig is the application config,
@@ -24,14 +25,20 @@ type ServerConf struct {
 	SessionSecure bool
 }
 
-// DatabaseConf set MySql server address, database name, username and password
+// DatabaseConf set database driver, server address, database name, username and password
 type DatabaseConf struct {
+	Driver string // mysql (default), postgres or sqlite, for sqlite Dbname is the database file
 	Server string
 	Port   int // Add this
 	Dbname string
 	Dbuser string
 	Dbpass string
 	UseTLS bool // Add this
+
+	QueryTimeout time.Duration // per query timeout, 0 = default (3 seconds), negative = no timeout
+
+	Migrations  string // migrations directory
+	AutoMigrate bool   // apply pending migrations in Controller.Initialize
 }
 
 // RateLimitConf for rate limiting configuration
@@ -147,6 +154,9 @@ func unmarshal(ncfg configValues) *AppConfig {
 		conf.Server.SessionSecure = ncfg.Get("server:SessionSecure").(bool)
 	}
 
+	if ncfg.Get("database:driver") != nil {
+		conf.Database.Driver = fmt.Sprint(ncfg.Get("database:driver"))
+	}
 	conf.Database.Server = fmt.Sprint(ncfg.Get("database:server"))
 	conf.Database.Dbname = fmt.Sprint(ncfg.Get("database:dbname"))
 	conf.Database.Dbuser = fmt.Sprint(ncfg.Get("database:dbuser"))
@@ -156,7 +166,14 @@ func unmarshal(ncfg configValues) *AppConfig {
 	if ncfg.Get("database:port") != nil {
 		conf.Database.Port = ncfg.Get("database:port").(int)
 	} else {
-		conf.Database.Port = 3306
+		switch conf.Database.Driver {
+		case "postgres", "postgresql", "pgsql":
+			conf.Database.Port = 5432
+		case "sqlite", "sqlite3":
+			conf.Database.Port = 0
+		default:
+			conf.Database.Port = 3306
+		}
 	}
 
 	// Database TLS - secure by default
@@ -166,6 +183,22 @@ func unmarshal(ncfg configValues) *AppConfig {
 		conf.Database.UseTLS = true // Secure by default
 	}
 
+	// Query timeout in seconds, 0 or negative disables the timeout
+	if ncfg.Get("database:queryTimeout") != nil {
+		conf.Database.QueryTimeout = time.Duration(ncfg.Get("database:queryTimeout").(int)) * time.Second
+		if conf.Database.QueryTimeout == 0 {
+			conf.Database.QueryTimeout = -1
+		}
+	}
+
+	// Migrations
+	if ncfg.Get("database:migrations") != nil {
+		conf.Database.Migrations = fmt.Sprint(ncfg.Get("database:migrations"))
+	}
+	if ncfg.Get("database:autoMigrate") != nil {
+		conf.Database.AutoMigrate = ncfg.Get("database:autoMigrate").(bool)
+	}
+
 	// Ratelimit configuration with secure defaults
 	if ncfg.Get("ratelimit:enabled") != nil {
 		conf.RateLimit.Enabled = ncfg.Get("ratelimit:enabled").(bool)
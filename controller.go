@@ -33,14 +33,17 @@
 package gomvc
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/fs"
 	"log"
 	"math/rand"
 	"net/http"
 	"net/url"
+	"os"
 	"path/filepath"
 	"strings"
 	"text/template"
@@ -90,6 +93,7 @@ type Controller struct {
 	Router                  *chi.Mux
 	Config                  *AppConfig
 	Functions               template.FuncMap
+	MigrationsFS            fs.FS // migrations applied by Initialize when database:autoMigrate is set, default is the database:migrations directory
 
 	IPRateLimiter   *RateLimiter // Rate limit by IP
 	UserRateLimiter *RateLimiter // Rate limit by username
@@ -101,17 +105,33 @@ type controllerOptions struct {
 	action    Action
 	hasTable  bool
 	needsAuth bool
+	useTx     bool
+	txAction  TxAction
+	fillable  []string
+	guarded   []string
 }
 
 // ActionRouting helps the router to have the routing information about the URL, the NextURL,
 // if the route needs authentication or if it is a web hook (web hook can have POST data without midleware CSRF check)
+// UseTx runs the built-in create / update action inside a transaction, TxAction (if set) runs in the same transaction
+// after the model write, returning an error rolls back the whole action.
+// For the create / update actions NextURL can contain {id}, it is replaced with the id of the new / updated record.
+// Fillable (replaces Model.Fillable) and Guarded (added to Model.Guarded) limit the form fields bound by the create / update action.
 type ActionRouting struct {
 	URL       string
 	NextURL   string
 	NeedsAuth bool
 	IsWebHook bool
+	UseTx     bool
+	TxAction  TxAction
+	Fillable  []string
+	Guarded   []string
 }
 
+// TxAction is executed inside the transaction of a built-in create / update action after the model write,
+// id is the primary key of the created (LastInsertId) / updated record.
+type TxAction func(tx *Tx, r *http.Request, id string) error
+
 // RequestObject is a struct builded from the http request, holds the url data in a convinient way.
 type RequestObject struct {
 	baseUrl string
@@ -129,6 +149,21 @@ type TemplateObject struct {
 // Build func map
 //var Functions = template.FuncMap{}
 
+// RunMigrations applies the pending migrations of fsys (and the Go migrations added with RegisterMigration)
+func (c *Controller) RunMigrations(fsys fs.FS) error {
+	if c.DB == nil {
+		return errors.New("controller has no database connection")
+	}
+
+	applied, err := NewMigrator(c.DB, fsys).Migrate(context.Background())
+	if err != nil {
+		return err
+	}
+	InfoMessage(fmt.Sprintf("%d migration(s) applied", len(applied)))
+
+	return nil
+}
+
 // Initialize from this function we pass a pointer to db connection and a pointer to appconfig struct
 func (c *Controller) Initialize(db *sql.DB, cfg *AppConfig) {
 	c.DB = db
@@ -143,6 +178,20 @@ func (c *Controller) Initialize(db *sql.DB, cfg *AppConfig) {
 		DisplayFirewallHelp(cfg.Server.Port)
 	}
 
+	// Apply pending migrations
+	if cfg.Database.AutoMigrate {
+		fmt.Println("")
+		InfoMessage(CenterText("MIGRATIONS", 40, '='))
+		fsys := c.MigrationsFS
+		if fsys == nil && len(cfg.Database.Migrations) > 0 {
+			fsys = os.DirFS(cfg.Database.Migrations)
+		}
+		if err := c.RunMigrations(fsys); err != nil {
+			ServerError(nil, err)
+			log.Fatal()
+		}
+	}
+
 	// Initialize rate limiters if enabled
 	fmt.Println("")
 	InfoMessage(CenterText("INITIALIZE RATE LIMITS", 40, '='))
@@ -269,16 +318,17 @@ func secureHeaders(cfg *AppConfig) func(http.Handler) http.Handler {
 
 // getControllerOptionsKey get the (key or id) from URL, Controller key function
 func (r *ActionRouting) getControllerOptionsKey(action Action) string {
-	cKey := r.URL
-	if strings.Contains(r.URL, "*") {
-		cKey = strings.Replace(r.URL, "*", "", 1)
-	}
-	if strings.Contains(r.URL, "{id}") {
-		cKey = strings.Replace(r.URL, "{id}", "", 1)
-	}
 	//cKey = cKey + "-" + fmt.Sprint(action)
+	return routeKey(r.URL)
+}
 
-	return cKey
+// routeKey returns the controller key of a route pattern, the part before the first URL parameter or wildcard:
+// /products/view/{id} -> /products/view/ and /items/{order_id}/{line_no} -> /items/
+func routeKey(pattern string) string {
+	if i := strings.IndexAny(pattern, "{*"); i > -1 {
+		return pattern[:i]
+	}
+	return pattern
 }
 
 // GetSession return session manager
@@ -333,7 +383,8 @@ func (c *Controller) RegisterAction(route ActionRouting, action Action, model *M
 		hasTable = true
 	}
 
-	c.Options[cKey] = controllerOptions{next: route.NextURL, action: action, hasTable: hasTable, needsAuth: route.NeedsAuth}
+	c.Options[cKey] = controllerOptions{next: route.NextURL, action: action, hasTable: hasTable, needsAuth: route.NeedsAuth,
+		useTx: route.UseTx || route.TxAction != nil, txAction: route.TxAction, fillable: route.Fillable, guarded: route.Guarded}
 
 	if action == ActionView {
 		c.Router.With(noSurf).Get(route.URL, c.viewAction)
@@ -622,6 +673,16 @@ func parseRequest(r *http.Request, homePageFilename string) RequestObject {
 		params["***KEY***"] = []interface{}{paramsStr}
 	}
 
+	// Routes with URL parameters, e.g. /items/{order_id}/{line_no}, are keyed by their pattern
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && strings.Contains(rctx.RoutePattern(), "{") {
+		cntrlr, action, _, baseUrl = exportControllerAndAction(routeKey(rctx.RoutePattern()))
+		for i, k := range rctx.URLParams.Keys {
+			if _, ok := params[k]; !ok && i < len(rctx.URLParams.Values) {
+				params[k] = []interface{}{rctx.URLParams.Values[i]}
+			}
+		}
+	}
+
 	//Build params from url string [part 2]
 	if len(rParts) > 1 {
 		tmp2 := strings.Split(rParts[1], "&")
@@ -779,7 +840,7 @@ func (c *Controller) authAction(w http.ResponseWriter, r *http.Request) {
 	}
 
 	//Get single row [user record]
-	rr, err := m.GetRecords(f, 1)
+	rr, err := m.GetRecordsContext(r.Context(), f, 1)
 	if err != nil {
 		ServerError(w, err)
 		return
@@ -841,7 +902,7 @@ func (c *Controller) authAction(w http.ResponseWriter, r *http.Request) {
 		fields = append(fields, SQLField{FieldName: Auth.ExpTimeFieldName, Value: exp})
 
 		// Update user record with session token
-		_, err = m.Update(fields, userID)
+		_, err = m.UpdateContext(r.Context(), fields, userID)
 
 		if err != nil {
 			ServerError(w, err)
@@ -1041,6 +1102,7 @@ func (c *Controller) GetLinuxUsername(r *http.Request) string {
 // viewAction is the View Action Function (CRUD), used for GET requests --- GET ---
 func (c *Controller) viewAction(w http.ResponseWriter, r *http.Request) {
 	var rr []ResultRow
+	var pagination *Pagination
 	var err error
 
 	rObj := parseRequest(r, c.TemplateHomePage)
@@ -1071,46 +1133,49 @@ func (c *Controller) viewAction(w http.ResponseWriter, r *http.Request) {
 
 	if cOptions.hasTable {
 		m := c.Models[rObj.baseUrl]
-		if len(rObj.params) == 0 {
-			// Get all rows
-			rr, err = m.GetRecords([]Filter{}, 0)
-			if err != nil {
-				ServerError(w, err)
-				return
-			}
-		} else {
-			// Build filter -> only for primary key
-			f := make([]Filter, 0)
-			fv, ok := rObj.params["***KEY***"]
-			if ok {
-				f = append(f, Filter{Field: m.TableName + "." + m.PKField, Operator: "=", Value: fv[0]})
-			}
 
-			// Multiple filters -> ?filters={"name":"ford","description":"2021"}
-			for k, v := range rObj.params {
-				if k == "filters" {
-					for _, vv := range v {
-						vvMap, _ := vv.(map[string]interface{})
-						for kkk, vvv := range vvMap {
-							if FindInSlice(m.Fields, kkk) > -1 {
-								if len(f) > 0 {
-									f = append(f, Filter{Field: m.TableName + "." + kkk, Operator: " LIKE ", Value: "%" + vvv.(string) + "%", Logic: "AND"})
-								} else {
-									f = append(f, Filter{Field: m.TableName + "." + kkk, Operator: " LIKE ", Value: "%" + vvv.(string) + "%"})
-								}
-
-							}
-						}
-					}
+		// Filters and sort order -> ?sort=-price,name&filter[price][gte]=100&filter[status][in]=a,b
+		qb, err := m.NewQueryBuilderFromURL(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Multiple filters (legacy) -> ?filters={"name":"ford","description":"2021"}
+		for _, f := range m.legacyFilters(rObj.params) {
+			qb.addWhere(f, "AND")
+		}
+
+		page, perPage, cursor, paged := pageParams(rObj.params)
+		if key, ok := requestKey(m, r, rObj); ok {
+			// Get single row by primary key
+			rr, err = qb.whereKey(key).Limit(1).ExecuteContext(r.Context())
+		} else if len(m.DefaultQuery) > 0 {
+			// Default query, filters are not supported
+			rr, err = m.GetRecordsContext(r.Context(), []Filter{}, 0)
+		} else if paged {
+			// Paginated rows -> ?page=2&per_page=20 or ?cursor=120&per_page=20
+			var p Pagination
+			if len(cursor) > 0 || page == 0 {
+				if len(qb.orderBy) > 0 {
+					http.Error(w, "sort is not supported with cursor pagination", http.StatusBadRequest)
+					return
 				}
+				rr, p, err = qb.PaginateCursorContext(r.Context(), cursor, perPage)
+			} else {
+				rr, p, err = qb.PaginateContext(r.Context(), page, perPage)
 			}
-
-			//Get single row
-			rr, err = m.GetRecords(f, 1)
-			if err != nil {
-				ServerError(w, err)
-				return
+			if err == nil {
+				p.SetLinks(r.URL)
+				pagination = &p
 			}
+		} else {
+			// Get all matching rows
+			rr, err = qb.ExecuteContext(r.Context())
+		}
+		if err != nil {
+			ServerError(w, err)
+			return
 		}
 	}
 
@@ -1141,6 +1206,7 @@ func (c *Controller) viewAction(w http.ResponseWriter, r *http.Request) {
 	td.Auth = Auth
 	td.AuthExpired, _ = Auth.IsSessionExpired(r)
 	td.Result = rr
+	td.Pagination = pagination
 	td.URLParams = rObj.params
 	m, ok := c.Models[rObj.baseUrl]
 	if ok {
@@ -1148,10 +1214,223 @@ func (c *Controller) viewAction(w http.ResponseWriter, r *http.Request) {
 	}
 
 	td = c.AddTemplateData(td, r)
+	if state, ok := r.Context().Value(formStateKey{}).(*formState); ok {
+		td.FormErrors = state.errors
+		td.OldInput = state.input
+	}
 
 	c.View(t, &td, w, r)
 }
 
+// hookAborted shows the error of a model hook that aborted the write as flash error (.Error) and renders the view,
+// false for other errors
+func (c *Controller) hookAborted(w http.ResponseWriter, r *http.Request, err error) bool {
+	var he *HookError
+	if !errors.As(err, &he) {
+		return false
+	}
+
+	InfoMessage("Write aborted by hook: " + he.Error())
+	Session.Put(r.Context(), "error", he.Err.Error())
+	c.viewAction(w, r)
+	return true
+}
+
+// formStateKey is the request context key of the form state passed from createAction / updateAction to viewAction
+type formStateKey struct{}
+
+// formState is a posted form that failed validation
+type formState struct {
+	errors ValidationErrors
+	input  url.Values
+}
+
+// formInvalid renders the view with the validation errors (.FormErrors) and the posted values (.OldInput)
+func (c *Controller) formInvalid(w http.ResponseWriter, r *http.Request, verrs ValidationErrors) {
+	InfoMessage("Form validation failed: " + verrs.Error())
+	state := &formState{errors: verrs, input: r.PostForm}
+	c.viewAction(w, r.WithContext(context.WithValue(r.Context(), formStateKey{}, state)))
+}
+
+// requestKey returns the primary key of the record addressed by the request: the URL parameters named after the
+// key columns (/items/{order_id}/{line_no}), for single column keys also {id} or the id of /controller/action/id
+func requestKey(m *Model, r *http.Request, rObj RequestObject) (Key, bool) {
+	fields := m.KeyFields()
+	if len(fields) == 0 {
+		return nil, false
+	}
+
+	key := Key{}
+	for _, f := range fields {
+		if v := chi.URLParam(r, f); len(v) > 0 {
+			key[f] = v
+		}
+	}
+	if len(key) == len(fields) {
+		return key, true
+	}
+	if len(fields) > 1 {
+		return nil, false
+	}
+
+	if v := chi.URLParam(r, "id"); len(v) > 0 {
+		return Key{fields[0]: v}, true
+	}
+	if fv, ok := rObj.params["***KEY***"]; ok {
+		return Key{fields[0]: fmt.Sprint(fv[0])}, true
+	}
+	return nil, false
+}
+
+// missingKeyError is the error of an update / delete request without the primary key of the record
+func missingKeyError(m *Model) error {
+	pk := strings.Join(m.KeyFields(), ", ")
+	return errors.New("Table's primary key [" + pk + "] not found in parameters array." +
+		"Url parameters must have [" + pk + "] as parameter OR table must have [id] field as primary key")
+}
+
+// keyURL replaces {id} (the key as text) and the {key column} placeholders of a NextURL with the key of the record
+func keyURL(next string, m *Model, key Key) string {
+	next = strings.ReplaceAll(next, "{id}", m.keyText(key))
+	for f, v := range key {
+		next = strings.ReplaceAll(next, "{"+f+"}", fmt.Sprint(v))
+	}
+	return next
+}
+
+// insertKey returns the key of a record inserted by createAction: the key values of the insert fields,
+// LastInsertId for the auto increment key column without value. Models without primary key use {id}: LastInsertId.
+func insertKey(m *Model, fields []SQLField, res WriteResult) Key {
+	kf := m.KeyFields()
+	if len(kf) == 0 {
+		return Key{"id": res.LastInsertId}
+	}
+
+	key := Key{}
+	for _, f := range kf {
+		if i := fieldIndex(fields, f); i > -1 && fields[i].Value != nil {
+			key[f] = fields[i].Value
+		} else if autoIncrementKey(m, f) {
+			key[f] = res.LastInsertId
+		}
+	}
+	return key
+}
+
+// autoIncrementKey reports whether the database generates the value of a key column,
+// without column metadata a single column key is taken as auto increment
+func autoIncrementKey(m *Model, field string) bool {
+	if c := m.Column(field); c != nil {
+		return c.AutoIncrement
+	}
+	return len(m.KeyFields()) == 1
+}
+
+// FormNullKey and FormEmptyKey are the form keys listing the fields set to NULL or to an empty string,
+// e.g. <input type="hidden" name="_null" value="price">
+const (
+	FormNullKey  = "_null"
+	FormEmptyKey = "_empty"
+)
+
+// formFields binds the posted form to the model fields used by createAction and updateAction:
+// fields missing from the form or posted empty are left unchanged, fields listed in FormNullKey are set to NULL
+// and fields listed in FormEmptyKey are set to an empty string. Fields that are not fillable are ignored.
+func formFields(m *Model, opts controllerOptions, r *http.Request) ([]SQLField, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+
+	nulls := r.Form[FormNullKey]
+	empties := r.Form[FormEmptyKey]
+
+	var fields []SQLField
+	for _, f := range m.Fields {
+		if !fillable(m, opts, f) {
+			continue
+		}
+		switch {
+		case FindInSlice(nulls, f) > -1:
+			fields = append(fields, SQLField{FieldName: f, Value: nil})
+		case FindInSlice(empties, f) > -1:
+			fields = append(fields, SQLField{FieldName: f, Value: ""})
+		default:
+			if fv := r.Form.Get(f); fv != "" {
+				fields = append(fields, SQLField{FieldName: f, Value: fv})
+			}
+		}
+	}
+
+	return fields, nil
+}
+
+// fillable reports whether the create / update action binds a form field: it must be a column of the model table
+// (not a relation field), the route Fillable (or Model.Fillable) must list it when set, it must not be guarded by
+// the route or the model, and the guarded by default fields (auto increment key, timestamps, soft delete, AuthObject
+// password / hash code / expiration) must be listed in Fillable. The VersionField is always bound, the optimistic
+// lock check needs the posted version.
+func fillable(m *Model, opts controllerOptions, field string) bool {
+	if m.Column(field) == nil {
+		return false
+	}
+	if len(m.VersionField) > 0 && field == m.VersionField {
+		return true
+	}
+
+	allowed := opts.fillable
+	if len(allowed) == 0 {
+		allowed = m.Fillable
+	}
+	listed := FindInSlice(allowed, field) > -1
+
+	if len(allowed) > 0 && !listed {
+		return false
+	}
+	if FindInSlice(opts.guarded, field) > -1 || FindInSlice(m.Guarded, field) > -1 {
+		return false
+	}
+	if !listed && FindInSlice(defaultGuarded(m), field) > -1 {
+		return false
+	}
+	return true
+}
+
+// validateForm returns the context of the createAction / updateAction write (nil key for create): the fields are validated
+// after the before hooks, so fields set by hooks pass the Required rules, and the write fails with the ValidationErrors.
+// Errors of fields that are not fillable are dropped because the form can't correct them.
+func validateForm(ctx context.Context, opts controllerOptions, key Key) context.Context {
+	return withWriteCheck(ctx, func(ctx context.Context, tm *Model, fields []SQLField) error {
+		verrs, err := tm.ValidateByKeyContext(ctx, fields, key)
+		if err != nil {
+			return err
+		}
+		for f := range verrs {
+			if !fillable(tm, opts, f) {
+				delete(verrs, f)
+			}
+		}
+		if len(verrs) > 0 {
+			return verrs
+		}
+		return nil
+	})
+}
+
+// defaultGuarded returns the fields of a model that are guarded unless listed in Fillable: the auto increment
+// key column (other key columns, e.g. of a composite key, are posted by the form), timestamps and soft delete
+func defaultGuarded(m *Model) []string {
+	out := []string{m.CreatedAtField, m.UpdatedAtField, m.SoftDeleteField}
+	for _, f := range m.KeyFields() {
+		if c := m.Column(f); c != nil && c.AutoIncrement {
+			out = append(out, f)
+		}
+	}
+	if len(Auth.Model.TableName) > 0 && Auth.Model.TableName == m.TableName {
+		out = append(out, Auth.PasswordFieldName, Auth.HashCodeFieldName, Auth.ExpTimeFieldName)
+	}
+	return out
+}
+
 // createAction is the CREATE function (CRUD), used for POST requests --- POST ---
 func (c *Controller) createAction(w http.ResponseWriter, r *http.Request) {
 	var err error
@@ -1193,25 +1472,47 @@ func (c *Controller) createAction(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var fields []SQLField
-
-	for _, f := range m.Fields {
-		var fv = r.Form.Get(f)
-		if fv != "" {
-			fields = append(fields, SQLField{FieldName: f, Value: fv})
-		}
+	fields, err := formFields(m, cOptions, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	InfoMessage("Starting Create process !!!")
 
-	_, err = m.Insert(fields)
+	ctx := validateForm(r.Context(), cOptions, nil)
+	var res WriteResult
+	if cOptions.useTx {
+		err = WithTx(r.Context(), m.DB, func(tx *Tx) error {
+			var err error
+			res, err = m.WithTx(tx).InsertContext(ctx, fields)
+			if err != nil {
+				return err
+			}
+			if cOptions.txAction == nil {
+				return nil
+			}
+			return cOptions.txAction(tx, r, m.keyText(insertKey(m, fields, res)))
+		})
+	} else {
+		res, err = m.InsertContext(ctx, fields)
+	}
+	var verrs ValidationErrors
+	if errors.As(err, &verrs) {
+		c.formInvalid(w, r, verrs)
+		return
+	}
 	if err != nil {
+		if c.hookAborted(w, r, err) {
+			return
+		}
 		ServerError(w, err)
 		return
 	}
 
+	// NextURL can use the new record key, e.g. /products/view/{id} or /lines/view/{order_id}/{line_no}
 	if len(cOptions.next) > 0 {
-		http.Redirect(w, r, string(cOptions.next), http.StatusSeeOther)
+		http.Redirect(w, r, keyURL(cOptions.next, m, insertKey(m, fields, res)), http.StatusSeeOther)
 	} else {
 		c.viewAction(w, r)
 	}
@@ -1257,33 +1558,57 @@ func (c *Controller) updateAction(w http.ResponseWriter, r *http.Request) {
 		ServerError(w, err)
 		return
 	}
-	var fields []SQLField
-
-	for _, f := range m.Fields {
-		var fv = r.Form.Get(f)
-		if fv != "" {
-			fields = append(fields, SQLField{FieldName: f, Value: fv})
-		}
+	fields, err := formFields(m, cOptions, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	InfoMessage("Starting Update process !!!")
 
-	id, ok := rObj.params["***KEY***"]
+	key, ok := requestKey(m, r, rObj)
 	if ok {
-		_, err = m.Update(fields, fmt.Sprint(id[0]))
+		ctx := validateForm(r.Context(), cOptions, key)
+		if cOptions.useTx {
+			err = WithTx(r.Context(), m.DB, func(tx *Tx) error {
+				if _, err := m.WithTx(tx).UpdateByKeyContext(ctx, fields, key); err != nil {
+					return err
+				}
+				if cOptions.txAction == nil {
+					return nil
+				}
+				return cOptions.txAction(tx, r, m.keyText(key))
+			})
+		} else {
+			_, err = m.UpdateByKeyContext(ctx, fields, key)
+		}
+		var verrs ValidationErrors
+		if errors.As(err, &verrs) {
+			c.formInvalid(w, r, verrs)
+			return
+		}
+		if errors.Is(err, ErrStaleRecord) {
+			// Optimistic locking conflict, show the current record
+			InfoMessage(err.Error())
+			Session.Put(r.Context(), "error", StaleRecordMessage)
+			c.viewAction(w, r)
+			return
+		}
 		if err != nil {
+			if c.hookAborted(w, r, err) {
+				return
+			}
 			ServerError(w, err)
 			return
 		}
 	} else {
-		err = errors.New("Table's primary key [" + m.PKField + "] not found in parameters array." +
-			"Url parameters must have [" + m.PKField + "] as parameter OR table must have [id] field as primary key")
+		err = missingKeyError(m)
 		ServerError(w, err)
 		return
 	}
 
 	if len(cOptions.next) > 0 {
-		http.Redirect(w, r, cOptions.next, http.StatusSeeOther)
+		http.Redirect(w, r, keyURL(cOptions.next, m, key), http.StatusSeeOther)
 	} else {
 		c.viewAction(w, r)
 	}
@@ -1333,16 +1658,18 @@ func (c *Controller) deleteAction(w http.ResponseWriter, r *http.Request) {
 
 	InfoMessage("Starting Delete process !!!")
 
-	id, ok := rObj.params["***KEY***"]
+	key, ok := requestKey(m, r, rObj)
 	if ok {
-		_, err = m.Delete(fmt.Sprint(id[0]))
+		_, err = m.DeleteByKeyContext(r.Context(), key)
 		if err != nil {
+			if c.hookAborted(w, r, err) {
+				return
+			}
 			ServerError(w, err)
 			return
 		}
 	} else {
-		err = errors.New("Table's primary key [" + m.PKField + "] not found in parameters array." +
-			"Url parameters must have [" + m.PKField + "] as parameter OR table must have [id] field as primary key")
+		err = missingKeyError(m)
 		ServerError(w, err)
 		return
 	}
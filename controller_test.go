@@ -0,0 +1,172 @@
+package gomvc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/alexedwards/scs/v2"
+)
+
+// testController returns a controller with the create action of m at route (e.g. /cars/create), the view of a failed
+// form is the template text
+func testController(t *testing.T, m *Model, route string, next string, text string) *Controller {
+	t.Helper()
+	if Session == nil {
+		Session = scs.New()
+	}
+
+	page := strings.ReplaceAll(strings.TrimPrefix(route, "/"), "/", ".") + ".tmpl"
+	tmpl, err := template.New(page).Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Controller{
+		DB:            m.DB,
+		Config:        &AppConfig{UseCache: true},
+		Models:        map[string]*Model{route: m},
+		Options:       map[string]controllerOptions{route: {next: next, action: ActionCreate, hasTable: true}},
+		TemplateCache: map[string]TemplateObject{page: {template: tmpl}},
+	}
+	return c
+}
+
+// postForm posts form to the create action of c at route
+func postForm(c *Controller, route string, form url.Values) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", route, strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	Session.LoadAndSave(http.HandlerFunc(c.createAction)).ServeHTTP(w, r)
+	return w
+}
+
+func TestFormFields(t *testing.T) {
+	m := testModel(t)
+
+	tests := []struct {
+		name string
+		form url.Values
+		want []SQLField
+	}{
+		{"posted", url.Values{"name": {"ford"}, "price": {"10.50"}},
+			[]SQLField{{FieldName: "name", Value: "ford"}, {FieldName: "price", Value: "10.50"}}},
+		{"empty skipped", url.Values{"name": {"ford"}, "price": {""}},
+			[]SQLField{{FieldName: "name", Value: "ford"}}},
+		{"null", url.Values{"price": {"10.50"}, FormNullKey: {"price"}},
+			[]SQLField{{FieldName: "price", Value: nil}}},
+		{"empty", url.Values{"name": {""}, FormEmptyKey: {"name"}},
+			[]SQLField{{FieldName: "name", Value: ""}}},
+		{"null before empty", url.Values{FormNullKey: {"price"}, FormEmptyKey: {"price", "name"}},
+			[]SQLField{{FieldName: "name", Value: ""}, {FieldName: "price", Value: nil}}},
+		{"not fillable", url.Values{"id": {"7"}, "brand": {"x"}, FormNullKey: {"id", "brand"}, "version": {"3"}},
+			[]SQLField{{FieldName: "version", Value: "3"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/cars/create", strings.NewReader(tt.form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			got, err := formFields(m, controllerOptions{}, r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) || (len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+				t.Errorf("formFields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCreateActionValidationSQLite(t *testing.T) {
+	m := testModel(t)
+	m.AddRules("price", Required(), Range(1, 1000))
+	// price is set by the hook before the validation
+	m.AddHook(BeforeInsert, func(ctx context.Context, hc *HookContext) error {
+		for _, f := range hc.Fields {
+			if f.FieldName == "name" && f.Value == "hook" {
+				return errors.New("hook said no")
+			}
+		}
+		if fieldIndex(hc.Fields, "price") < 0 {
+			hc.Fields = append(hc.Fields, SQLField{FieldName: "price", Value: "5"})
+		}
+		return nil
+	})
+	c := testController(t, m, "/cars/create", "/cars/view/{id}", `{{.FormErrors.First "name"}}|{{.FormErrors.First "price"}}|{{.Old "notes"}}|{{index .OldInput "_null"}}|{{.Error}}`)
+
+	w := postForm(c, "/cars/create", url.Values{"notes": {`<b>"x"</b>`}, FormNullKey: {"price"}})
+	want := `name is required|price is required|&lt;b&gt;&#34;x&#34;&lt;/b&gt;|[price]|`
+	if w.Code != http.StatusOK || w.Body.String() != want {
+		t.Errorf("invalid form = %d %q, want 200 %q", w.Code, w.Body.String(), want)
+	}
+
+	w = postForm(c, "/cars/create", url.Values{"name": {"ford"}})
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/cars/view/1" {
+		t.Errorf("valid form = %d %q, want redirect to /cars/view/1", w.Code, w.Header().Get("Location"))
+	}
+	if rr, err := m.GetRecords(nil, 0); err != nil || len(rr) != 1 || rr[0].String("price") != "5" {
+		t.Errorf("cars = %v, %v, want ford with the hook price", rr, err)
+	}
+
+	w = postForm(c, "/cars/create", url.Values{"name": {"hook"}})
+	if w.Code != http.StatusOK || !strings.HasSuffix(w.Body.String(), "|hook said no") {
+		t.Errorf("form aborted by hook = %d %q, want the hook error", w.Code, w.Body.String())
+	}
+}
+
+func TestFillable(t *testing.T) {
+	m := postsModel(t)
+	m.VersionField = "version" // not a column of posts
+
+	tests := []struct {
+		name    string
+		model   []string // Model.Fillable
+		guarded []string // Model.Guarded
+		opts    controllerOptions
+		want    []string
+	}{
+		{"default", nil, nil, controllerOptions{}, []string{"title"}},
+		{"model fillable", []string{"title", "created_at"}, nil, controllerOptions{}, []string{"title", "created_at"}},
+		{"route fillable replaces model", []string{"title"}, nil, controllerOptions{fillable: []string{"id"}}, []string{"id"}},
+		{"model guarded", nil, []string{"title"}, controllerOptions{}, []string{}},
+		{"route guarded", []string{"title", "id"}, nil, controllerOptions{guarded: []string{"id"}}, []string{"title"}},
+		{"unknown fields", []string{"title", "comments", "version"}, nil, controllerOptions{}, []string{"title"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m.Fillable, m.Guarded = tt.model, tt.guarded
+			got := make([]string, 0)
+			for _, f := range m.Fields {
+				if fillable(m, tt.opts, f) {
+					got = append(got, f)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fillable fields = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// the version is always bound for the optimistic locking check
+	m.Fillable, m.Guarded = nil, nil
+	v := testModel(t)
+	v.VersionField = "version"
+	if !fillable(v, controllerOptions{guarded: []string{"version"}}, "version") {
+		t.Error("VersionField is not fillable")
+	}
+
+	// the password of the auth model is guarded
+	defer func(a AuthObject) { Auth = a }(Auth)
+	Auth.Model.TableName, Auth.PasswordFieldName = "posts", "title"
+	if fillable(m, controllerOptions{}, "title") {
+		t.Error("the auth password field is fillable")
+	}
+}
@@ -1,15 +1,31 @@
 package gomvc
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 )
 
-// ConnectDatabase
+// ConnectDatabase opens a connection to the database server selected by cfg.Driver (mysql, postgres, sqlite)
+// and registers the matching Dialect for the connection.
 func ConnectDatabase(cfg DatabaseConf) (*sql.DB, error) {
+	d, err := DialectByName(cfg.Driver)
+	if err != nil {
+		return nil, err
+	}
+
+	switch d.(type) {
+	case PostgresDialect:
+		return ConnectDatabasePostgres(cfg)
+	case SQLiteDialect:
+		return ConnectDatabaseSQLite(cfg.Dbname)
+	}
+
 	tlsParam := ""
 	if cfg.UseTLS {
 		tlsParam = "&tls=true"
@@ -20,7 +36,8 @@ func ConnectDatabase(cfg DatabaseConf) (*sql.DB, error) {
 		port = 3306 // default
 	}
 
-	cstring := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true%s",
+	// clientFoundRows: RowsAffected counts matched rows, not only the changed ones
+	cstring := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true&clientFoundRows=true%s",
 		cfg.Dbuser,
 		cfg.Dbpass,
 		cfg.Server,
@@ -44,13 +61,55 @@ func ConnectDatabase(cfg DatabaseConf) (*sql.DB, error) {
 		return nil, fmt.Errorf("database connection failed: %w", err)
 	}
 
+	RegisterDialect(db, MySQLDialect{})
+
 	return db, nil
 }
 
-// ConnectDatabase
+// ConnectDatabasePostgres opens a connection to a PostgreSQL server
+func ConnectDatabasePostgres(cfg DatabaseConf) (*sql.DB, error) {
+	sslMode := "disable"
+	if cfg.UseTLS {
+		sslMode = "require"
+	}
+
+	port := cfg.Port
+	if port == 0 {
+		port = 5432 // default
+	}
+
+	cstring := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		quoteConnValue(cfg.Server),
+		port,
+		quoteConnValue(cfg.Dbuser),
+		quoteConnValue(cfg.Dbpass),
+		quoteConnValue(cfg.Dbname),
+		sslMode,
+	)
+
+	db, err := sql.Open("postgres", cstring)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open PostgreSQL connection: %w", err)
+	}
+
+	db.SetConnMaxLifetime(time.Minute * 3)
+	db.SetMaxOpenConns(10)
+	db.SetMaxIdleConns(10)
+
+	// Test connection
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("PostgreSQL connection failed: %w", err)
+	}
+
+	RegisterDialect(db, PostgresDialect{})
+
+	return db, nil
+}
+
+// ConnectDatabaseSQLite opens an SQLite database file, use ":memory:" or "file::memory:?cache=shared" for an in-memory database
 func ConnectDatabaseSQLite(dbname string) (*sql.DB, error) {
-	//cstring := user + ":" + pass + "@/" + dbname
-	db, err := sql.Open("sqlite3", dbname) // "user:password@/dbname"
+	db, err := sql.Open("sqlite3", dbname)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open SQLite database: %w", err)
 	}
@@ -61,5 +120,39 @@ func ConnectDatabaseSQLite(dbname string) (*sql.DB, error) {
 		return nil, fmt.Errorf("SQLite ping failed: %w", err)
 	}
 
+	RegisterDialect(db, SQLiteDialect{})
+
 	return db, nil
 }
+
+// defaultQueryTimeout is the query timeout used when DatabaseConf.QueryTimeout is not set
+const defaultQueryTimeout = 3 * time.Second
+
+// queryContext applies the configured per-query timeout (DatabaseConf.QueryTimeout) to ctx
+func queryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	timeout := defaultQueryTimeout
+	if cfg != nil && cfg.Database.QueryTimeout != 0 {
+		timeout = cfg.Database.QueryTimeout
+	}
+	if timeout < 0 {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// quoteConnValue quotes a value for a PostgreSQL key=value connection string
+func quoteConnValue(s string) string {
+	r := ""
+	for _, c := range s {
+		if c == '\\' || c == '\'' {
+			r = r + "\\"
+		}
+		r = r + string(c)
+	}
+	return "'" + r + "'"
+}
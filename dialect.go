@@ -0,0 +1,604 @@
+package gomvc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Dialect describes the SQL flavour of a database server, the query builder and the models
+// use it for everything that is not portable between MySql, PostgreSQL and SQLite.
+type Dialect interface {
+	// Name returns the dialect name: "mysql", "postgres" or "sqlite"
+	Name() string
+	// Placeholder returns the bind parameter for the n-th (1 based) value of a statement
+	Placeholder(n int) string
+	// QuoteIdent quotes a table or column name, dotted names (table.column) are quoted per part
+	QuoteIdent(name string) string
+	// LimitOffset returns the LIMIT / OFFSET clause, empty string when both are zero
+	LimitOffset(limit int64, offset int64) string
+	// Columns returns the columns of a table in ordinal order
+	Columns(ctx context.Context, db *sql.DB, tableName string) ([]Column, error)
+	// ForeignKeys returns the foreign key constraints of a table
+	ForeignKeys(ctx context.Context, db *sql.DB, tableName string) ([]ForeignKey, error)
+	// ReferencedBy returns the foreign key constraints of other tables (and self references) referencing a table
+	ReferencedBy(ctx context.Context, db *sql.DB, tableName string) ([]ForeignKey, error)
+	// Upsert returns the clause appended to an INSERT statement to update updateFields
+	// when a row with the same conflictKeys already exists, a clause updating fields ends with the SET assignments
+	Upsert(conflictKeys []string, updateFields []string) string
+	// Returning returns the clause appended to an INSERT statement to read the generated key,
+	// empty string when the driver supports sql.Result.LastInsertId
+	Returning(pkField string) string
+}
+
+var (
+	dialectsMu     sync.RWMutex
+	dialects               = make(map[*sql.DB]Dialect)
+	defaultDialect Dialect = MySQLDialect{}
+)
+
+// RegisterDialect assigns a dialect to a database connection,
+// the Connect functions call it for you.
+func RegisterDialect(db *sql.DB, d Dialect) {
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+	dialects[db] = d
+}
+
+// SetDefaultDialect sets the dialect used for connections without a registered dialect
+// and by the package level BuildQuery functions, the Connect functions don't change it.
+func SetDefaultDialect(d Dialect) {
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+	defaultDialect = d
+}
+
+// GetDialect returns the dialect registered for a database connection or the default dialect
+func GetDialect(db *sql.DB) Dialect {
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+	if d, ok := dialects[db]; ok {
+		return d
+	}
+	return defaultDialect
+}
+
+// DialectByName returns the dialect for a driver name as used in the config file (database:driver)
+func DialectByName(name string) (Dialect, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "mysql":
+		return MySQLDialect{}, nil
+	case "postgres", "postgresql", "pgsql":
+		return PostgresDialect{}, nil
+	case "sqlite", "sqlite3":
+		return SQLiteDialect{}, nil
+	}
+	return nil, errors.New("unknown database driver: " + name)
+}
+
+// ====================================================================== MySql ======================================================================
+
+// MySQLDialect is the MySql / MariaDB dialect
+type MySQLDialect struct{}
+
+// Name returns the dialect name
+func (MySQLDialect) Name() string { return "mysql" }
+
+// Placeholder returns ? for every value
+func (MySQLDialect) Placeholder(n int) string { return "?" }
+
+// QuoteIdent quotes identifiers with backticks
+func (MySQLDialect) QuoteIdent(name string) string { return quoteIdentWith(name, "`") }
+
+// LimitOffset returns the LIMIT / OFFSET clause, MySql needs a LIMIT when OFFSET is used
+func (MySQLDialect) LimitOffset(limit int64, offset int64) string {
+	if limit <= 0 && offset <= 0 {
+		return ""
+	}
+	l := " LIMIT 18446744073709551615"
+	if limit > 0 {
+		l = " LIMIT " + strconv.FormatInt(limit, 10)
+	}
+	if offset > 0 {
+		l = l + " OFFSET " + strconv.FormatInt(offset, 10)
+	}
+	return l
+}
+
+// Columns reads the table columns with SHOW COLUMNS
+func (d MySQLDialect) Columns(ctx context.Context, db *sql.DB, tableName string) ([]Column, error) {
+	rows, err := queryMaps(ctx, db, "SHOW COLUMNS FROM "+d.QuoteIdent(tableName))
+	if err != nil {
+		return nil, err
+	}
+
+	cols := make([]Column, 0, len(rows))
+	for _, r := range rows {
+		c := Column{Name: mapText(r, "field"), Key: strings.ToUpper(mapText(r, "key"))}
+		parseColumnType(&c, mapText(r, "type"))
+		c.Nullable = strings.EqualFold(mapText(r, "null"), "YES")
+		c.HasDefault = r["default"] != nil
+		c.Default = columnDefault(mapText(r, "default"))
+		c.AutoIncrement = strings.Contains(strings.ToLower(mapText(r, "extra")), "auto_increment")
+		cols = append(cols, c)
+	}
+	return checkColumns(cols)
+}
+
+// ForeignKeys reads the foreign keys from information_schema in the current database
+func (MySQLDialect) ForeignKeys(ctx context.Context, db *sql.DB, tableName string) ([]ForeignKey, error) {
+	rows, err := queryMaps(ctx, db, "SELECT table_name, constraint_name, column_name, referenced_table_name, referenced_column_name "+
+		"FROM information_schema.key_column_usage WHERE table_schema = DATABASE() AND table_name = ? AND referenced_table_name IS NOT NULL "+
+		"ORDER BY constraint_name, ordinal_position", tableName)
+	if err != nil {
+		return nil, err
+	}
+	return groupForeignKeys(rows), nil
+}
+
+// ReferencedBy reads the foreign keys referencing the table from information_schema in the current database
+func (MySQLDialect) ReferencedBy(ctx context.Context, db *sql.DB, tableName string) ([]ForeignKey, error) {
+	rows, err := queryMaps(ctx, db, "SELECT table_name, constraint_name, column_name, referenced_table_name, referenced_column_name "+
+		"FROM information_schema.key_column_usage WHERE table_schema = DATABASE() AND referenced_table_schema = DATABASE() AND referenced_table_name = ? "+
+		"ORDER BY table_name, constraint_name, ordinal_position", tableName)
+	if err != nil {
+		return nil, err
+	}
+	return groupForeignKeys(rows), nil
+}
+
+// Upsert returns an ON DUPLICATE KEY UPDATE clause, MySql detects the conflict from the table keys
+func (d MySQLDialect) Upsert(conflictKeys []string, updateFields []string) string {
+	if len(updateFields) == 0 {
+		if len(conflictKeys) == 0 {
+			return ""
+		}
+		k := d.QuoteIdent(conflictKeys[0])
+		return " ON DUPLICATE KEY UPDATE " + k + " = " + k
+	}
+	set := make([]string, len(updateFields))
+	for i, f := range updateFields {
+		set[i] = d.QuoteIdent(f) + " = VALUES(" + d.QuoteIdent(f) + ")"
+	}
+	return " ON DUPLICATE KEY UPDATE " + strings.Join(set, ", ")
+}
+
+// Returning is not needed, the driver supports LastInsertId
+func (MySQLDialect) Returning(pkField string) string { return "" }
+
+// ====================================================================== PostgreSQL ======================================================================
+
+// PostgresDialect is the PostgreSQL dialect
+type PostgresDialect struct{}
+
+// Name returns the dialect name
+func (PostgresDialect) Name() string { return "postgres" }
+
+// Placeholder returns $1, $2 ...
+func (PostgresDialect) Placeholder(n int) string { return "$" + strconv.Itoa(n) }
+
+// QuoteIdent quotes identifiers with double quotes
+func (PostgresDialect) QuoteIdent(name string) string { return quoteIdentWith(name, `"`) }
+
+// LimitOffset returns the LIMIT / OFFSET clause
+func (PostgresDialect) LimitOffset(limit int64, offset int64) string {
+	l := ""
+	if limit > 0 {
+		l = " LIMIT " + strconv.FormatInt(limit, 10)
+	}
+	if offset > 0 {
+		l = l + " OFFSET " + strconv.FormatInt(offset, 10)
+	}
+	return l
+}
+
+// Columns reads the table columns, keys and enum values from information_schema / pg_enum in the current schema
+func (PostgresDialect) Columns(ctx context.Context, db *sql.DB, tableName string) ([]Column, error) {
+	rows, err := queryMaps(ctx, db, "SELECT column_name, data_type, udt_name, character_maximum_length, is_nullable, column_default, is_identity "+
+		"FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = $1 ORDER BY ordinal_position", tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	var enums map[string][]string
+	cols := make([]Column, 0, len(rows))
+	for _, r := range rows {
+		c := Column{Name: mapText(r, "column_name"), Type: strings.ToUpper(mapText(r, "udt_name"))}
+		c.Length, _ = strconv.ParseInt(mapText(r, "character_maximum_length"), 10, 64)
+		c.Nullable = strings.EqualFold(mapText(r, "is_nullable"), "YES")
+		c.AutoIncrement = strings.HasPrefix(mapText(r, "column_default"), "nextval(") || strings.EqualFold(mapText(r, "is_identity"), "YES")
+		c.HasDefault = r["column_default"] != nil || c.AutoIncrement
+		c.Default = columnDefault(mapText(r, "column_default"))
+
+		if mapText(r, "data_type") == "USER-DEFINED" {
+			if enums == nil {
+				if enums, err = pgEnums(ctx, db); err != nil {
+					return nil, err
+				}
+			}
+			if values, ok := enums[mapText(r, "udt_name")]; ok {
+				c.Type, c.Enum = "ENUM", values
+			}
+		}
+		cols = append(cols, c)
+	}
+
+	keys, err := queryMaps(ctx, db, "SELECT tc.constraint_name, tc.constraint_type, kcu.column_name, kcu.ordinal_position, "+
+		"(SELECT COUNT(*) FROM information_schema.key_column_usage k WHERE k.constraint_schema = tc.constraint_schema AND k.constraint_name = tc.constraint_name) AS column_count "+
+		"FROM information_schema.table_constraints tc JOIN information_schema.key_column_usage kcu "+
+		"ON kcu.constraint_schema = tc.constraint_schema AND kcu.constraint_name = tc.constraint_name AND kcu.table_name = tc.table_name "+
+		"WHERE tc.table_schema = current_schema() AND tc.table_name = $1 AND tc.constraint_type IN ('PRIMARY KEY', 'UNIQUE', 'FOREIGN KEY')", tableName)
+	if err != nil {
+		return nil, err
+	}
+	for _, k := range keys {
+		switch mapText(k, "constraint_type") {
+		case "PRIMARY KEY":
+			setColumnKey(cols, mapText(k, "column_name"), "PRI")
+		case "UNIQUE":
+			setColumnKey(cols, mapText(k, "column_name"), indexKey(true, mapText(k, "ordinal_position") == "1", mapText(k, "column_count") == "1"))
+		default:
+			setColumnKey(cols, mapText(k, "column_name"), "MUL")
+		}
+	}
+
+	return checkColumns(cols)
+}
+
+// pgEnums returns the values of the enum types by type name
+func pgEnums(ctx context.Context, db *sql.DB) (map[string][]string, error) {
+	rows, err := queryMaps(ctx, db, "SELECT t.typname, e.enumlabel FROM pg_type t JOIN pg_enum e ON e.enumtypid = t.oid "+
+		"ORDER BY t.typname, e.enumsortorder")
+	if err != nil {
+		return nil, err
+	}
+
+	enums := make(map[string][]string)
+	for _, r := range rows {
+		n := mapText(r, "typname")
+		enums[n] = append(enums[n], mapText(r, "enumlabel"))
+	}
+	return enums, nil
+}
+
+// pgForeignKeys selects the foreign key columns in the current schema, the WHERE condition is appended
+const pgForeignKeys = "SELECT kcu.table_name, kcu.constraint_name, kcu.column_name, ref.table_name AS referenced_table_name, ref.column_name AS referenced_column_name " +
+	"FROM information_schema.referential_constraints rc " +
+	"JOIN information_schema.key_column_usage kcu ON kcu.constraint_schema = rc.constraint_schema AND kcu.constraint_name = rc.constraint_name " +
+	"JOIN information_schema.key_column_usage ref ON ref.constraint_schema = rc.unique_constraint_schema " +
+	"AND ref.constraint_name = rc.unique_constraint_name AND ref.ordinal_position = kcu.position_in_unique_constraint " +
+	"WHERE kcu.table_schema = current_schema() "
+
+// ForeignKeys reads the foreign keys from information_schema in the current schema
+func (PostgresDialect) ForeignKeys(ctx context.Context, db *sql.DB, tableName string) ([]ForeignKey, error) {
+	rows, err := queryMaps(ctx, db, pgForeignKeys+"AND kcu.table_name = $1 ORDER BY kcu.constraint_name, kcu.ordinal_position", tableName)
+	if err != nil {
+		return nil, err
+	}
+	return groupForeignKeys(rows), nil
+}
+
+// ReferencedBy reads the foreign keys referencing the table from information_schema in the current schema
+func (PostgresDialect) ReferencedBy(ctx context.Context, db *sql.DB, tableName string) ([]ForeignKey, error) {
+	rows, err := queryMaps(ctx, db, pgForeignKeys+"AND ref.table_schema = current_schema() AND ref.table_name = $1 ORDER BY kcu.table_name, kcu.constraint_name, kcu.ordinal_position", tableName)
+	if err != nil {
+		return nil, err
+	}
+	return groupForeignKeys(rows), nil
+}
+
+// Upsert returns an ON CONFLICT clause
+func (d PostgresDialect) Upsert(conflictKeys []string, updateFields []string) string {
+	return onConflictClause(d, conflictKeys, updateFields)
+}
+
+// Returning returns a RETURNING clause, the PostgreSQL driver does not support LastInsertId
+func (d PostgresDialect) Returning(pkField string) string {
+	return " RETURNING " + d.QuoteIdent(pkField)
+}
+
+// ====================================================================== SQLite ======================================================================
+
+// SQLiteDialect is the SQLite 3 dialect
+type SQLiteDialect struct{}
+
+// Name returns the dialect name
+func (SQLiteDialect) Name() string { return "sqlite" }
+
+// Placeholder returns ? for every value
+func (SQLiteDialect) Placeholder(n int) string { return "?" }
+
+// QuoteIdent quotes identifiers with double quotes
+func (SQLiteDialect) QuoteIdent(name string) string { return quoteIdentWith(name, `"`) }
+
+// LimitOffset returns the LIMIT / OFFSET clause, SQLite needs a LIMIT when OFFSET is used
+func (SQLiteDialect) LimitOffset(limit int64, offset int64) string {
+	if limit <= 0 && offset <= 0 {
+		return ""
+	}
+	l := " LIMIT -1"
+	if limit > 0 {
+		l = " LIMIT " + strconv.FormatInt(limit, 10)
+	}
+	if offset > 0 {
+		l = l + " OFFSET " + strconv.FormatInt(offset, 10)
+	}
+	return l
+}
+
+// Columns reads the table columns with PRAGMA table_info, an INTEGER PRIMARY KEY is auto increment (rowid)
+func (d SQLiteDialect) Columns(ctx context.Context, db *sql.DB, tableName string) ([]Column, error) {
+	rows, err := queryMaps(ctx, db, "PRAGMA table_info("+d.QuoteIdent(tableName)+")")
+	if err != nil {
+		return nil, err
+	}
+
+	pkCount := 0
+	for _, r := range rows {
+		if mapText(r, "pk") != "0" {
+			pkCount++
+		}
+	}
+
+	cols := make([]Column, 0, len(rows))
+	for _, r := range rows {
+		c := Column{Name: mapText(r, "name")}
+		parseColumnType(&c, mapText(r, "type"))
+		pk := mapText(r, "pk") != "0"
+		if pk {
+			c.Key = "PRI"
+		}
+		c.AutoIncrement = pk && pkCount == 1 && c.Type == "INTEGER"
+		c.Nullable = mapText(r, "notnull") == "0" && !c.AutoIncrement
+		c.HasDefault = r["dflt_value"] != nil || c.AutoIncrement
+		c.Default = columnDefault(mapText(r, "dflt_value"))
+		cols = append(cols, c)
+	}
+
+	indexes, err := queryMaps(ctx, db, "PRAGMA index_list("+d.QuoteIdent(tableName)+")")
+	if err != nil {
+		return nil, err
+	}
+	for _, ix := range indexes {
+		info, err := queryMaps(ctx, db, "PRAGMA index_info("+d.QuoteIdent(mapText(ix, "name"))+")")
+		if err != nil {
+			return nil, err
+		}
+		for _, ic := range info {
+			setColumnKey(cols, mapText(ic, "name"), indexKey(mapText(ix, "unique") == "1", mapText(ic, "seqno") == "0", len(info) == 1))
+		}
+	}
+
+	fks, err := d.ForeignKeys(ctx, db, tableName)
+	if err != nil {
+		return nil, err
+	}
+	for _, fk := range fks {
+		setColumnKey(cols, fk.Columns[0], "MUL")
+	}
+
+	return checkColumns(cols)
+}
+
+// ForeignKeys reads the foreign keys with PRAGMA foreign_key_list, references without columns use the primary key
+// of the referenced table
+func (d SQLiteDialect) ForeignKeys(ctx context.Context, db *sql.DB, tableName string) ([]ForeignKey, error) {
+	rows, err := queryMaps(ctx, db, "PRAGMA foreign_key_list("+d.QuoteIdent(tableName)+")")
+	if err != nil {
+		return nil, err
+	}
+
+	var fks []ForeignKey
+	id := ""
+	for _, r := range rows {
+		if len(fks) == 0 || mapText(r, "id") != id {
+			id = mapText(r, "id")
+			fks = append(fks, ForeignKey{Table: tableName, RefTable: mapText(r, "table")})
+		}
+		fk := &fks[len(fks)-1]
+		fk.Columns = append(fk.Columns, mapText(r, "from"))
+		if r["to"] != nil {
+			fk.RefColumns = append(fk.RefColumns, mapText(r, "to"))
+		}
+	}
+
+	for i, fk := range fks {
+		if len(fk.RefColumns) == len(fk.Columns) {
+			continue
+		}
+		ref, err := queryMaps(ctx, db, "PRAGMA table_info("+d.QuoteIdent(fk.RefTable)+")")
+		if err != nil {
+			return nil, err
+		}
+		pks := make([]string, len(fk.Columns))
+		for _, c := range ref {
+			if n, _ := strconv.Atoi(mapText(c, "pk")); n > 0 && n <= len(pks) {
+				pks[n-1] = mapText(c, "name")
+			}
+		}
+		fks[i].RefColumns = pks
+	}
+
+	return fks, nil
+}
+
+// ReferencedBy reads the foreign keys of every table and returns those referencing the table
+func (d SQLiteDialect) ReferencedBy(ctx context.Context, db *sql.DB, tableName string) ([]ForeignKey, error) {
+	tables, err := queryMaps(ctx, db, "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []ForeignKey
+	for _, t := range tables {
+		fks, err := d.ForeignKeys(ctx, db, mapText(t, "name"))
+		if err != nil {
+			return nil, err
+		}
+		for _, fk := range fks {
+			if strings.EqualFold(fk.RefTable, tableName) {
+				refs = append(refs, fk)
+			}
+		}
+	}
+	return refs, nil
+}
+
+// Upsert returns an ON CONFLICT clause
+func (d SQLiteDialect) Upsert(conflictKeys []string, updateFields []string) string {
+	return onConflictClause(d, conflictKeys, updateFields)
+}
+
+// Returning is not needed, the driver supports LastInsertId
+func (SQLiteDialect) Returning(pkField string) string { return "" }
+
+// ====================================================================== helpers ======================================================================
+
+// quoteIdentWith quotes every part of a dotted identifier, * is left as is
+func quoteIdentWith(name string, q string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		if p == "*" {
+			continue
+		}
+		parts[i] = q + strings.ReplaceAll(p, q, q+q) + q
+	}
+	return strings.Join(parts, ".")
+}
+
+// onConflictClause is the ON CONFLICT clause shared by PostgreSQL and SQLite
+func onConflictClause(d Dialect, conflictKeys []string, updateFields []string) string {
+	keys := make([]string, len(conflictKeys))
+	for i, k := range conflictKeys {
+		keys[i] = d.QuoteIdent(k)
+	}
+	c := " ON CONFLICT (" + strings.Join(keys, ", ") + ")"
+	if len(updateFields) == 0 {
+		return c + " DO NOTHING"
+	}
+	set := make([]string, len(updateFields))
+	for i, f := range updateFields {
+		set[i] = d.QuoteIdent(f) + " = EXCLUDED." + d.QuoteIdent(f)
+	}
+	return c + " DO UPDATE SET " + strings.Join(set, ", ")
+}
+
+// queryMaps runs an introspection query and returns the rows as maps of lower case column names,
+// []byte values are converted to string
+func queryMaps(ctx context.Context, db *sql.DB, q string, args ...interface{}) ([]map[string]interface{}, error) {
+	r, err := db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	cols, err := r.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]map[string]interface{}, 0)
+	for r.Next() {
+		values := make([]interface{}, len(cols))
+		pointers := make([]interface{}, len(cols))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+
+		if err := r.Scan(pointers...); err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]interface{}, len(cols))
+		for i, c := range cols {
+			if b, ok := values[i].([]byte); ok {
+				values[i] = string(b)
+			}
+			row[strings.ToLower(c)] = values[i]
+		}
+		rows = append(rows, row)
+	}
+	if err := r.Err(); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// mapText returns a queryMaps value as text, "" for NULL
+func mapText(row map[string]interface{}, key string) string {
+	if v := row[key]; v != nil {
+		return valueText(v)
+	}
+	return ""
+}
+
+// groupForeignKeys builds the foreign keys from rows of table_name, constraint_name, column_name, referenced_table_name
+// and referenced_column_name ordered by table, constraint and column position
+func groupForeignKeys(rows []map[string]interface{}) []ForeignKey {
+	var fks []ForeignKey
+	for _, r := range rows {
+		table, name := mapText(r, "table_name"), mapText(r, "constraint_name")
+		if len(fks) == 0 || fks[len(fks)-1].Table != table || fks[len(fks)-1].Name != name {
+			fks = append(fks, ForeignKey{Name: name, Table: table, RefTable: mapText(r, "referenced_table_name")})
+		}
+		fk := &fks[len(fks)-1]
+		fk.Columns = append(fk.Columns, mapText(r, "column_name"))
+		fk.RefColumns = append(fk.RefColumns, mapText(r, "referenced_column_name"))
+	}
+	return fks
+}
+
+// indexKey returns the key of an index column like MySql SHOW COLUMNS: UNI for a single column unique index,
+// MUL for the first column of other indexes, empty for the other columns
+func indexKey(unique, first, single bool) string {
+	switch {
+	case !first:
+		return ""
+	case unique && single:
+		return "UNI"
+	}
+	return "MUL"
+}
+
+// checkColumns returns an error for tables without columns (the table does not exist)
+func checkColumns(cols []Column) ([]Column, error) {
+	if len(cols) == 0 {
+		return nil, errors.New("table not found or has no columns")
+	}
+	return cols, nil
+}
+
+// rebind rewrites ? placeholders to the dialect placeholder style, quoted strings are left untouched
+func rebind(d Dialect, q string) string {
+	if d.Placeholder(1) == "?" {
+		return q
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(q) + 8)
+
+	n := 0
+	var quote byte
+	for i := 0; i < len(q); i++ {
+		ch := q[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+		case ch == '?':
+			n++
+			sb.WriteString(d.Placeholder(n))
+			continue
+		}
+		sb.WriteByte(ch)
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,74 @@
+package gomvc
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		name string
+		d    Dialect
+		q    string
+		want string
+	}{
+		{"mysql unchanged", MySQLDialect{}, "SELECT * FROM t WHERE a = ? AND b = ?", "SELECT * FROM t WHERE a = ? AND b = ?"},
+		{"sqlite unchanged", SQLiteDialect{}, "SELECT * FROM t WHERE a = ?", "SELECT * FROM t WHERE a = ?"},
+		{"postgres numbered", PostgresDialect{}, "SELECT * FROM t WHERE a = ? AND b IN (?, ?)", "SELECT * FROM t WHERE a = $1 AND b IN ($2, $3)"},
+		{"postgres quoted", PostgresDialect{}, `SELECT '?', "a?b" FROM t WHERE a = ?`, `SELECT '?', "a?b" FROM t WHERE a = $1`},
+		{"postgres no placeholders", PostgresDialect{}, "SELECT 1", "SELECT 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rebind(tt.d, tt.q); got != tt.want {
+				t.Errorf("rebind(%q) = %q, want %q", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimitOffset(t *testing.T) {
+	tests := []struct {
+		name          string
+		d             Dialect
+		limit, offset int64
+		want          string
+	}{
+		{"mysql none", MySQLDialect{}, 0, 0, ""},
+		{"mysql limit", MySQLDialect{}, 10, 0, " LIMIT 10"},
+		{"mysql limit offset", MySQLDialect{}, 10, 20, " LIMIT 10 OFFSET 20"},
+		{"mysql offset", MySQLDialect{}, 0, 20, " LIMIT 18446744073709551615 OFFSET 20"},
+		{"postgres none", PostgresDialect{}, 0, 0, ""},
+		{"postgres limit offset", PostgresDialect{}, 10, 20, " LIMIT 10 OFFSET 20"},
+		{"postgres offset", PostgresDialect{}, 0, 20, " OFFSET 20"},
+		{"sqlite none", SQLiteDialect{}, 0, 0, ""},
+		{"sqlite limit", SQLiteDialect{}, 5, 0, " LIMIT 5"},
+		{"sqlite offset", SQLiteDialect{}, 0, 20, " LIMIT -1 OFFSET 20"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.d.LimitOffset(tt.limit, tt.offset); got != tt.want {
+				t.Errorf("LimitOffset(%d, %d) = %q, want %q", tt.limit, tt.offset, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConnectKeepsDefaultDialect(t *testing.T) {
+	before := GetDialect(nil)
+
+	db, err := ConnectDatabaseSQLite(filepath.Join(t.TempDir(), "dialect.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if d := GetDialect(db); d.Name() != "sqlite" {
+		t.Errorf("GetDialect(db) = %s, want sqlite", d.Name())
+	}
+	if d := GetDialect(nil); d != before {
+		t.Errorf("GetDialect(nil) = %s after connect, want %s", d.Name(), before.Name())
+	}
+}
@@ -0,0 +1,150 @@
+package gomvc
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// DiscoveredRelation is a relation found by DiscoverRelations
+type DiscoveredRelation struct {
+	Type       RelationType
+	ForeignKey ForeignKey // the constraint the relation was found from
+	Skipped    string     // why the relation was not added, empty when it was added
+}
+
+// String describes the relation, e.g. belongs-to brands (cars.brand_id -> brands.id)
+func (d DiscoveredRelation) String() string {
+	fk := d.ForeignKey
+	table := fk.RefTable
+	if d.Type != RelationBelongsTo {
+		table = fk.Table
+	}
+
+	s := d.Type.String() + " " + table + " (" + fk.Table + "." + strings.Join(fk.Columns, ", "+fk.Table+".") +
+		" -> " + fk.RefTable + "." + strings.Join(fk.RefColumns, ", "+fk.RefTable+".") + ")"
+	if len(d.Skipped) > 0 {
+		s += " skipped: " + d.Skipped
+	}
+	return s
+}
+
+// RelationReport lists the relations found by DiscoverRelations
+type RelationReport struct {
+	Table   string
+	Added   []DiscoveredRelation
+	Skipped []DiscoveredRelation
+}
+
+// String returns the report, one relation per line
+func (r RelationReport) String() string {
+	var sb strings.Builder
+	sb.WriteString("relations of " + r.Table + ": " + strconv.Itoa(len(r.Added)) + " added, " + strconv.Itoa(len(r.Skipped)) + " skipped")
+	for _, d := range r.Added {
+		sb.WriteString("\n  " + d.String())
+	}
+	for _, d := range r.Skipped {
+		sb.WriteString("\n  " + d.String())
+	}
+	return sb.String()
+}
+
+// DiscoverRelations reads the foreign keys of the model table and of the tables referencing it and adds the relations:
+// belongs-to for the foreign keys of the table, has-many (has-one when the referencing column is unique) for
+// the foreign keys referencing it. The relations are LEFT joins loaded as Subresult, composite foreign keys and
+// relations that are already registered are skipped. Call it after InitModel.
+func (m *Model) DiscoverRelations() (RelationReport, error) {
+	return m.DiscoverRelationsContext(context.Background())
+}
+
+// DiscoverRelationsContext is DiscoverRelations with a context
+func (m *Model) DiscoverRelationsContext(ctx context.Context) (RelationReport, error) {
+	if m == nil {
+		return RelationReport{}, errors.New("cannot perform action: DiscoverRelations() on nil model")
+	}
+	report := RelationReport{Table: m.TableName}
+
+	qctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	own, err := m.dialect().ForeignKeys(qctx, m.DB, m.TableName)
+	if err != nil {
+		return report, err
+	}
+	refs, err := m.dialect().ReferencedBy(qctx, m.DB, m.TableName)
+	if err != nil {
+		return report, err
+	}
+
+	found := make([]DiscoveredRelation, 0, len(own)+len(refs))
+	for _, fk := range own {
+		found = append(found, DiscoveredRelation{Type: RelationBelongsTo, ForeignKey: fk})
+	}
+	for _, fk := range refs {
+		found = append(found, DiscoveredRelation{Type: RelationHasMany, ForeignKey: fk})
+	}
+
+	for _, d := range found {
+		fk := d.ForeignKey
+		if len(fk.Columns) != 1 || len(fk.RefColumns) != 1 {
+			d.Skipped = "composite foreign key"
+			report.Skipped = append(report.Skipped, d)
+			continue
+		}
+
+		table, keys := fk.RefTable, SQLKeyPair{LocalKey: fk.Columns[0], ForeignKey: fk.RefColumns[0]}
+		if d.Type != RelationBelongsTo {
+			table, keys = fk.Table, SQLKeyPair{LocalKey: fk.RefColumns[0], ForeignKey: fk.Columns[0]}
+		}
+		if m.hasRelation(table, keys) {
+			d.Skipped = "already registered"
+			report.Skipped = append(report.Skipped, d)
+			continue
+		}
+
+		fm := &Model{Dialect: m.Dialect}
+		if err := fm.InitModelContext(ctx, m.DB, table, ""); err != nil {
+			return report, err
+		}
+		fm.PKField = primaryKey(fm.Columns)
+		if d.Type == RelationBelongsTo && len(fm.PKField) == 0 {
+			fm.PKField = keys.ForeignKey
+		}
+		if d.Type == RelationHasMany {
+			if c := fm.Column(keys.ForeignKey); c != nil && (c.Key == "UNI" || (c.Key == "PRI" && fm.PKField == c.Name)) {
+				d.Type = RelationHasOne
+			}
+		}
+
+		m.addRelation(fm, keys, SQLPivot{}, ModelJoinLeft, ResultStyleSubresult, d.Type)
+		report.Added = append(report.Added, d)
+	}
+
+	InfoMessage(report.String())
+	return report, nil
+}
+
+// hasRelation reports whether a relation to the foreign table with the same keys is registered
+func (m *Model) hasRelation(table string, keys SQLKeyPair) bool {
+	for _, r := range m.Relations {
+		if r.Join.Foreign_table == table && r.Join.KeyPair == keys {
+			return true
+		}
+	}
+	return false
+}
+
+// primaryKey returns the primary key column, empty when the table has no or a composite primary key
+func primaryKey(cols []Column) string {
+	pk := ""
+	for _, c := range cols {
+		if c.Key == "PRI" {
+			if len(pk) > 0 {
+				return ""
+			}
+			pk = c.Name
+		}
+	}
+	return pk
+}
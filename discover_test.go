@@ -0,0 +1,71 @@
+package gomvc
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestDiscoverRelationsSQLite(t *testing.T) {
+	db := relationDB(t)
+	for _, q := range []string{
+		`CREATE TABLE car_details (car_id INTEGER PRIMARY KEY REFERENCES cars(id), color VARCHAR(20))`,
+		`CREATE TABLE car_notes (car_id INTEGER, car_name VARCHAR(50), note TEXT,
+			FOREIGN KEY (car_id, car_name) REFERENCES cars(id, name))`,
+		`INSERT INTO car_details (car_id, color) VALUES (1, 'red')`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cars := relationModel(t, db, "cars")
+
+	report, err := cars.DiscoverRelations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	added := make([]string, len(report.Added))
+	for i, d := range report.Added {
+		added[i] = d.String()
+	}
+	sort.Strings(added)
+	want := []string{
+		"belongs-to brands (cars.brand_id -> brands.id)",
+		"has-many parts (parts.car_id -> cars.id)",
+		"has-one car_details (car_details.car_id -> cars.id)",
+	}
+	if len(added) != len(want) {
+		t.Fatalf("added = %v, want %v", added, want)
+	}
+	for i := range want {
+		if added[i] != want[i] {
+			t.Errorf("added = %v, want %v", added, want)
+			break
+		}
+	}
+	if len(report.Skipped) != 1 || report.Skipped[0].Skipped != "composite foreign key" {
+		t.Errorf("skipped = %v, want the composite foreign key of car_notes", report.Skipped)
+	}
+
+	// the relations are loaded as subresult
+	r, err := cars.FindByKey(Key{"id": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables := make(map[string]int)
+	for _, sr := range r.Subresult {
+		tables[sr.TableName]++
+	}
+	if tables["brands"] != 1 || tables["parts"] != 2 || tables["car_details"] != 1 {
+		t.Errorf("subresult tables of car 1 = %v, want brands 1, parts 2, car_details 1", tables)
+	}
+
+	// registered relations are not added twice
+	report, err = cars.DiscoverRelations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Added) != 0 || len(report.Skipped) != 4 || len(cars.Relations) != 3 {
+		t.Errorf("second discovery added %d, skipped %d, relations %d, want 0, 4, 3",
+			len(report.Added), len(report.Skipped), len(cars.Relations))
+	}
+}
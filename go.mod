@@ -2,7 +2,11 @@ module github.com/kostasdak/gomvc
 
 go 1.17
 
-require golang.org/x/crypto v0.0.0-20220829220503-c86fa9a7ed90
+require (
+	github.com/lib/pq v1.10.9
+	github.com/mattn/go-sqlite3 v1.14.17
+	golang.org/x/crypto v0.0.0-20220829220503-c86fa9a7ed90
+)
 
 require (
 	github.com/alexedwards/scs/v2 v2.5.0
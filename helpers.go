@@ -41,7 +41,7 @@ func ServerError(w http.ResponseWriter, err error) {
 
 // InfoMessage print/log an INFO message -> send to info logger
 func InfoMessage(info string) {
-	if cfg.EnableInfoLog {
+	if cfg != nil && cfg.EnableInfoLog {
 		infoLog.Println(info)
 	}
 }
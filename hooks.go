@@ -0,0 +1,154 @@
+package gomvc
+
+import (
+	"context"
+	"strconv"
+)
+
+// HookEvent is the model event a hook is registered for
+type HookEvent int
+
+// Every before event is followed by its after event
+const (
+	BeforeInsert HookEvent = iota
+	AfterInsert
+	BeforeUpdate
+	AfterUpdate
+	BeforeDelete
+	AfterDelete
+	AfterFind
+)
+
+var hookEventNames = map[HookEvent]string{
+	BeforeInsert: "BeforeInsert", AfterInsert: "AfterInsert",
+	BeforeUpdate: "BeforeUpdate", AfterUpdate: "AfterUpdate",
+	BeforeDelete: "BeforeDelete", AfterDelete: "AfterDelete",
+	AfterFind: "AfterFind",
+}
+
+// String returns the event name
+func (e HookEvent) String() string {
+	if n, ok := hookEventNames[e]; ok {
+		return n
+	}
+	return "HookEvent(" + strconv.Itoa(int(e)) + ")"
+}
+
+// HookContext is passed to the hooks of an event. Before hooks can change Fields, AfterFind hooks can change Rows.
+// Model is bound to the transaction of the write, use it for queries that must be part of the write.
+type HookContext struct {
+	Model  *Model
+	Event  HookEvent
+	Fields []SQLField  // insert / update fields
+	ID     string      // primary key of Update / Delete / Restore (Key.String for composite keys), empty for query builder writes
+	Key    Key         // primary key of Update / Delete and the ByKey writes
+	Result WriteResult // result of the write in after hooks
+	Rows   []ResultRow // AfterFind rows
+}
+
+// Hook is a model lifecycle hook, returning an error aborts the write (or the read for AfterFind)
+type Hook func(ctx context.Context, hc *HookContext) error
+
+// HookError is returned when a hook aborts a write or a read, the built-in CRUD actions show Err as flash error
+type HookError struct {
+	Event HookEvent
+	Err   error
+}
+
+func (e *HookError) Error() string {
+	return e.Event.String() + ": " + e.Err.Error()
+}
+
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// AddHook registers a hook for a model event, hooks run in the order they are added.
+// The writes and their hooks run in one transaction (a savepoint when the model is bound to a transaction),
+// an error of an after hook rolls back the write. Upsert and InsertMany run the insert hooks (InsertMany once per row,
+// with Result.RowsAffected 1 and the Key of the row fields, Key is nil when the database generates it), Restore runs the update hooks and soft deletes run the delete hooks.
+func (m *Model) AddHook(event HookEvent, fn Hook) {
+	if m.hooks == nil {
+		m.hooks = make(map[HookEvent][]Hook)
+	}
+	m.hooks[event] = append(m.hooks[event], fn)
+}
+
+// hasHooks reports whether hooks are registered for any of the events
+func (m *Model) hasHooks(events ...HookEvent) bool {
+	for _, e := range events {
+		if len(m.hooks[e]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// runHooks runs the hooks of hc.Event, the first error is returned as HookError
+func (m *Model) runHooks(ctx context.Context, hc *HookContext) error {
+	for _, fn := range m.hooks[hc.Event] {
+		if err := fn(ctx, hc); err != nil {
+			return &HookError{Event: hc.Event, Err: err}
+		}
+	}
+	return nil
+}
+
+// writeCheckKey is the context key of the writeCheck of a write
+type writeCheckKey struct{}
+
+// writeCheck checks the fields of a write after the before hooks changed them, an error aborts the write
+type writeCheck func(ctx context.Context, tm *Model, fields []SQLField) error
+
+// withWriteCheck returns a context running check in the next write after its before hooks,
+// createAction / updateAction validate the form with it so the fields set by hooks are validated too
+func withWriteCheck(ctx context.Context, check writeCheck) context.Context {
+	return context.WithValue(ctx, writeCheckKey{}, check)
+}
+
+// withHooks runs write between the before hooks of hc.Event and the matching after hooks in one transaction,
+// without hooks write runs directly on the model
+func (m *Model) withHooks(ctx context.Context, hc *HookContext, write func(tm *Model, fields []SQLField) (WriteResult, error)) (WriteResult, error) {
+	check, _ := ctx.Value(writeCheckKey{}).(writeCheck)
+	if check != nil {
+		// The writes of the hooks are not checked
+		ctx = withWriteCheck(ctx, nil)
+	}
+
+	before := hc.Event
+	after := before + 1
+	if !m.hasHooks(before, after) {
+		if check != nil {
+			if err := check(ctx, m, hc.Fields); err != nil {
+				return WriteResult{}, err
+			}
+		}
+		return write(m, hc.Fields)
+	}
+
+	var res WriteResult
+	err := m.inTx(ctx, func(tm *Model) error {
+		hc.Model = tm
+		if err := tm.runHooks(ctx, hc); err != nil {
+			return err
+		}
+		if check != nil {
+			if err := check(ctx, tm, hc.Fields); err != nil {
+				return err
+			}
+		}
+
+		var err error
+		if res, err = write(tm, hc.Fields); err != nil {
+			return err
+		}
+
+		hc.Event, hc.Result = after, res
+		return tm.runHooks(ctx, hc)
+	})
+	if err != nil {
+		return res, err
+	}
+
+	return res, nil
+}
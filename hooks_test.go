@@ -0,0 +1,81 @@
+package gomvc
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestHooksSQLite(t *testing.T) {
+	m := testModel(t)
+	ctx := context.Background()
+	errNoFiat := errors.New("no fiat")
+
+	var events []string
+	m.AddHook(BeforeInsert, func(ctx context.Context, hc *HookContext) error {
+		events = append(events, hc.Event.String())
+		for i, f := range hc.Fields {
+			if f.FieldName == "name" && f.Value == "fiat" {
+				return errNoFiat
+			}
+			if f.FieldName == "name" {
+				hc.Fields[i].Value = "car " + valueText(f.Value)
+			}
+		}
+		return nil
+	})
+	m.AddHook(AfterInsert, func(ctx context.Context, hc *HookContext) error {
+		events = append(events, hc.Event.String())
+		if hc.Result.LastInsertId == 0 {
+			t.Error("AfterInsert without LastInsertId")
+		}
+		// hc.Model is bound to the insert transaction, it sees the new row
+		n, err := hc.Model.NewQueryBuilder().CountContext(ctx)
+		if err != nil {
+			return err
+		}
+		if n > 1 {
+			return errors.New("only one car")
+		}
+		return nil
+	})
+	m.AddHook(BeforeDelete, func(ctx context.Context, hc *HookContext) error {
+		events = append(events, hc.Event.String()+" "+hc.ID)
+		return nil
+	})
+
+	if _, err := m.InsertContext(ctx, []SQLField{{FieldName: "name", Value: "ford"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var he *HookError
+	_, err := m.InsertContext(ctx, []SQLField{{FieldName: "name", Value: "fiat"}})
+	if !errors.As(err, &he) || he.Event != BeforeInsert || !errors.Is(err, errNoFiat) {
+		t.Errorf("BeforeInsert abort error = %v, want HookError %v", err, errNoFiat)
+	}
+
+	// The error of an after hook rolls back the insert
+	_, err = m.InsertContext(ctx, []SQLField{{FieldName: "name", Value: "bmw"}})
+	if !errors.As(err, &he) || he.Event != AfterInsert {
+		t.Errorf("AfterInsert abort error = %v, want HookError", err)
+	}
+
+	if got := carNames(t, m); len(got) != 1 || got[0] != "car ford" {
+		t.Errorf("cars = %v, want [car ford]", got)
+	}
+
+	if _, err := m.DeleteContext(ctx, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"BeforeInsert", "AfterInsert", "BeforeInsert", "BeforeInsert", "AfterInsert", "BeforeDelete 1"}
+	if len(events) != len(want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("events = %v, want %v", events, want)
+			break
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package gomvc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// RowIterator reads the rows of a query one at a time, use it for large result sets instead of Execute:
+//
+//	it, err := qb.Iterator()
+//	if err != nil { ... }
+//	defer it.Close()
+//	for it.Next() {
+//		row := it.Row()
+//	}
+//	if err := it.Err(); err != nil { ... }
+//
+// Subresult relations are not loaded (AfterFind hooks run for every row) and the query timeout is not applied, the query runs until ctx is done.
+// The iterator holds a database connection until it is closed, inside a transaction other queries
+// of the same transaction must wait until the iterator is closed.
+type RowIterator struct {
+	model  *Model
+	ctx    context.Context
+	rows   *sql.Rows
+	cancel context.CancelFunc
+	typ    []*sql.ColumnType
+	fld    []string
+	table  string
+	row    ResultRow
+	err    error
+}
+
+// Next scans the next row, it returns false when there are no more rows or on error
+func (it *RowIterator) Next() bool {
+	if it.err != nil || it.rows == nil {
+		return false
+	}
+
+	if !it.rows.Next() {
+		it.err = it.rows.Err()
+		it.Close()
+		return false
+	}
+
+	it.row, it.err = scanRow(it.rows, it.typ, it.fld, it.table)
+	if it.err == nil && it.model.hasHooks(AfterFind) {
+		// AfterFind hooks run for every row
+		hc := &HookContext{Model: it.model, Event: AfterFind, Rows: []ResultRow{it.row}}
+		if it.err = it.model.runHooks(it.ctx, hc); it.err == nil && len(hc.Rows) > 0 {
+			it.row = hc.Rows[0]
+		}
+	}
+	if it.err != nil {
+		it.Close()
+		return false
+	}
+
+	return true
+}
+
+// Row returns the current row
+func (it *RowIterator) Row() ResultRow {
+	return it.row
+}
+
+// Err returns the error that stopped the iteration
+func (it *RowIterator) Err() error {
+	return it.err
+}
+
+// Close releases the rows and the connection, it can be called more than once
+func (it *RowIterator) Close() error {
+	if it.rows == nil {
+		return nil
+	}
+	err := it.rows.Close()
+	it.rows = nil
+	if it.cancel != nil {
+		it.cancel()
+	}
+	return err
+}
+
+// Iterator executes the query and returns an iterator over the result rows
+func (qb *QueryBuilder) Iterator() (*RowIterator, error) {
+	return qb.IteratorContext(context.Background())
+}
+
+// IteratorContext is Iterator with a context
+func (qb *QueryBuilder) IteratorContext(ctx context.Context) (*RowIterator, error) {
+	q, values, err := qb.buildQuery()
+	if err != nil {
+		return nil, err
+	}
+
+	qb.model.lastQuery = q
+	qb.model.lastValues = values
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+
+	r, err := qb.model.conn().QueryContext(ctx, q, values...)
+	if err != nil {
+		cancel()
+		InfoMessage("Query failed: " + q)
+		return nil, err
+	}
+
+	typ, err := r.ColumnTypes()
+	if err != nil {
+		r.Close()
+		cancel()
+		return nil, err
+	}
+
+	fld, err := r.Columns()
+	if err != nil {
+		r.Close()
+		cancel()
+		return nil, err
+	}
+
+	return &RowIterator{model: qb.model, ctx: ctx, rows: r, cancel: cancel, typ: typ, fld: fld, table: qb.model.TableName}, nil
+}
+
+// Each executes the query and calls fn for every row without loading the whole result,
+// returning an error from fn stops the iteration and the error is returned
+func (qb *QueryBuilder) Each(fn func(row ResultRow) error) error {
+	return qb.EachContext(context.Background(), fn)
+}
+
+// EachContext is Each with a context
+func (qb *QueryBuilder) EachContext(ctx context.Context, fn func(row ResultRow) error) error {
+	it, err := qb.IteratorContext(ctx)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	for it.Next() {
+		if err := fn(it.Row()); err != nil {
+			return err
+		}
+	}
+
+	return it.Err()
+}
+
+// Chunk reads the result in chunks of size rows ordered by primary key and calls fn for every chunk,
+// every chunk is a separate query (WHERE pk > last pk of the previous chunk) so rows can be updated in fn.
+// Subresult relations are loaded per chunk. The builder must not have ORDER BY, LIMIT or OFFSET
+// and the selected columns must include the primary key.
+func (qb *QueryBuilder) Chunk(size int64, fn func(rows []ResultRow) error) error {
+	return qb.ChunkContext(context.Background(), size, fn)
+}
+
+// ChunkContext is Chunk with a context
+func (qb *QueryBuilder) ChunkContext(ctx context.Context, size int64, fn func(rows []ResultRow) error) error {
+	if size <= 0 {
+		return errors.New("chunk size must be greater than zero")
+	}
+	if len(qb.model.PKField) == 0 {
+		return errors.New("chunk needs a model with primary key")
+	}
+	if len(qb.orderBy) > 0 || qb.limit > 0 || qb.offset > 0 {
+		return errors.New("chunk does not support order by, limit and offset")
+	}
+
+	pk := qb.model.TableName + "." + qb.model.PKField
+	var last interface{}
+	for {
+		cqb := *qb
+		cqb.wheres = append([]Filter{}, qb.wheres...)
+		if last != nil {
+			if len(qb.wheres) > 0 {
+				// keep the original conditions together: (conditions) AND pk > last
+				cqb.wheres = []Filter{{Group: qb.wheres}}
+			}
+			cqb.addWhere(Filter{Field: pk, Operator: ">", Value: last}, "AND")
+		}
+		cqb.OrderBy(pk, "ASC").Limit(size)
+
+		rows, err := cqb.ExecuteContext(ctx)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+
+		idx := rows[len(rows)-1].GetFieldIndex(qb.model.PKField)
+		if idx < 0 {
+			return errors.New("chunk: primary key " + qb.model.PKField + " is not selected")
+		}
+		last = rows[len(rows)-1].Values[idx]
+		if last == nil {
+			return errors.New("chunk: primary key " + qb.model.PKField + " is NULL")
+		}
+
+		if err := fn(rows); err != nil {
+			return err
+		}
+
+		if int64(len(rows)) < size {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package gomvc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRowIteratorSQLite(t *testing.T) {
+	m := testModel(t)
+	insertCars(t, m, "ford", "bmw", "audi")
+
+	it, err := m.NewQueryBuilder().Where("id", ">", 1).OrderBy("id", "ASC").Iterator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+
+	names := make([]string, 0)
+	for it.Next() {
+		names = append(names, it.Row().String("name"))
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != "bmw" || names[1] != "audi" {
+		t.Errorf("iterated names = %v, want [bmw audi]", names)
+	}
+	if it.Next() {
+		t.Error("Next after the last row returned true")
+	}
+	if err := it.Close(); err != nil {
+		t.Errorf("second Close error = %v", err)
+	}
+
+	if _, err := m.NewQueryBuilder().Where("nope", "=", 1).Iterator(); err == nil {
+		t.Error("Iterator of an unknown column succeeded")
+	}
+}
+
+func TestEachSQLite(t *testing.T) {
+	m := testModel(t)
+	insertCars(t, m, "ford", "bmw", "audi")
+
+	var n int
+	if err := m.NewQueryBuilder().Each(func(row ResultRow) error { n++; return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("Each visited %d rows, want 3", n)
+	}
+
+	stop := errors.New("stop")
+	n = 0
+	err := m.NewQueryBuilder().OrderBy("id", "ASC").Each(func(row ResultRow) error {
+		n++
+		if row.String("name") == "bmw" {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || n != 2 {
+		t.Errorf("Each = %v after %d rows, want %v after 2", err, n, stop)
+	}
+}
+
+func TestChunkSQLite(t *testing.T) {
+	m := testModel(t)
+	insertCars(t, m, "a", "b", "c", "d", "e", "f", "g")
+
+	sizes := make([]int, 0)
+	err := m.NewQueryBuilder().Chunk(3, func(rows []ResultRow) error {
+		sizes = append(sizes, len(rows))
+		// rows can be changed in fn, the next chunk starts after the last key
+		for _, r := range rows {
+			if _, err := m.Delete(r.String("id")); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 3 || sizes[0] != 3 || sizes[2] != 1 {
+		t.Errorf("chunk sizes = %v, want [3 3 1]", sizes)
+	}
+	if got := carNames(t, m); len(got) != 0 {
+		t.Errorf("cars after deleting every chunk = %v, want none", got)
+	}
+
+	// OR conditions are kept together with the key condition
+	insertCars(t, m, "ford", "bmw", "audi", "fiat")
+	names := make([]string, 0)
+	err = m.NewQueryBuilder().Where("name", "=", "ford").OrWhere("name", "=", "fiat").Chunk(1, func(rows []ResultRow) error {
+		names = append(names, rows[0].String("name"))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != "ford" || names[1] != "fiat" {
+		t.Errorf("chunked names = %v, want [ford fiat]", names)
+	}
+
+	if err := m.NewQueryBuilder().OrderBy("name", "ASC").Chunk(2, func([]ResultRow) error { return nil }); err == nil {
+		t.Error("Chunk with ORDER BY succeeded")
+	}
+	if err := m.NewQueryBuilder().Chunk(0, func([]ResultRow) error { return nil }); err == nil {
+		t.Error("Chunk of size 0 succeeded")
+	}
+}
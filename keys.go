@@ -0,0 +1,175 @@
+package gomvc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Key is the primary key value of a record by key column, e.g. Key{"order_id": 7, "line_no": 2}
+type Key map[string]interface{}
+
+// String returns the key columns and values sorted by column, e.g. line_no=2,order_id=7
+func (k Key) String() string {
+	cols := make([]string, 0, len(k))
+	for c := range k {
+		cols = append(cols, c)
+	}
+	sort.Strings(cols)
+
+	parts := make([]string, len(cols))
+	for i, c := range cols {
+		parts[i] = c + "=" + fmt.Sprint(k[c])
+	}
+	return strings.Join(parts, ",")
+}
+
+// errCompositeKey is returned by the id based methods of models with a composite primary key
+var errCompositeKey = errors.New("model has a composite primary key, use the ByKey methods")
+
+// KeyFields returns the primary key columns: PKFields, or PKField when PKFields is empty
+func (m *Model) KeyFields() []string {
+	if len(m.PKFields) > 0 {
+		return m.PKFields
+	}
+	if len(m.PKField) > 0 {
+		return []string{m.PKField}
+	}
+	return nil
+}
+
+// idKey returns the key of a single column primary key
+func (m *Model) idKey(id string) (Key, error) {
+	fields := m.KeyFields()
+	switch len(fields) {
+	case 0:
+		return nil, errors.New("model " + m.TableName + " has no primary key")
+	case 1:
+		return Key{fields[0]: id}, nil
+	}
+	return nil, errCompositeKey
+}
+
+// fieldsKey returns the key of the primary key values in fields, nil when a key column has no value
+func (m *Model) fieldsKey(fields []SQLField) Key {
+	kf := m.KeyFields()
+	if len(kf) == 0 {
+		return nil
+	}
+
+	key := Key{}
+	for _, f := range kf {
+		i := fieldIndex(fields, f)
+		if i < 0 || fields[i].Value == nil {
+			return nil
+		}
+		key[f] = fields[i].Value
+	}
+	return key
+}
+
+// keyText returns the key as text: the value of a single column key, Key.String for composite keys
+func (m *Model) keyText(key Key) string {
+	if len(key) == 1 {
+		for _, v := range key {
+			return fmt.Sprint(v)
+		}
+	}
+	return key.String()
+}
+
+// keyFilters returns the filters matching the record of key, every key column must have a value.
+// Qualified filters use table.column names as needed by the query builder.
+func (m *Model) keyFilters(key Key, qualified bool) ([]Filter, error) {
+	fields := m.KeyFields()
+	if len(fields) == 0 {
+		return nil, errors.New("model " + m.TableName + " has no primary key")
+	}
+	if len(key) != len(fields) {
+		return nil, errors.New("key " + key.String() + " does not match the primary key (" + strings.Join(fields, ", ") + ")")
+	}
+
+	filters := make([]Filter, 0, len(fields))
+	for _, f := range fields {
+		v, ok := key[f]
+		if !ok {
+			return nil, errors.New("key " + key.String() + " has no value for " + f)
+		}
+		name := f
+		if qualified {
+			name = m.TableName + "." + f
+		}
+		filters = append(filters, Filter{Field: name, Operator: "=", Value: v, Logic: "AND"})
+	}
+	filters[0].Logic = ""
+	return filters, nil
+}
+
+// whereKey adds the conditions matching the record of key
+func (qb *QueryBuilder) whereKey(key Key) *QueryBuilder {
+	filters, err := qb.model.keyFilters(key, true)
+	if err != nil {
+		qb.setErr(err)
+		return qb
+	}
+	if len(filters) == 1 {
+		return qb.addWhere(filters[0], "AND")
+	}
+	return qb.addWhere(Filter{Group: filters}, "AND")
+}
+
+// FindByKey returns the record of key, ErrRecordNotFound when there is none
+func (m *Model) FindByKey(key Key) (ResultRow, error) {
+	return m.FindByKeyContext(context.Background(), key)
+}
+
+// FindByKeyContext is FindByKey with a context
+func (m *Model) FindByKeyContext(ctx context.Context, key Key) (ResultRow, error) {
+	if m == nil {
+		return ResultRow{}, errors.New("cannot perform action: FindByKey() on nil model")
+	}
+
+	rr, err := m.NewQueryBuilder().whereKey(key).Limit(1).ExecuteContext(ctx)
+	if err != nil {
+		return ResultRow{}, err
+	}
+	if len(rr) == 0 {
+		return ResultRow{}, ErrRecordNotFound
+	}
+	return rr[0], nil
+}
+
+// UpdateByKey is Update for the record of key, it works with composite primary keys (PKFields)
+func (m *Model) UpdateByKey(fields []SQLField, key Key) (WriteResult, error) {
+	return m.UpdateByKeyContext(context.Background(), fields, key)
+}
+
+// UpdateByKeyContext is UpdateByKey with a context
+func (m *Model) UpdateByKeyContext(ctx context.Context, fields []SQLField, key Key) (WriteResult, error) {
+	if m == nil {
+		return WriteResult{}, errors.New("cannot perform action: UpdateByKey() on nil model")
+	}
+
+	hc := &HookContext{Event: BeforeUpdate, Fields: fields, ID: m.keyText(key), Key: key}
+	return m.withHooks(ctx, hc, func(tm *Model, fields []SQLField) (WriteResult, error) {
+		return tm.update(ctx, fields, key)
+	})
+}
+
+// DeleteByKey is Delete for the record of key, it works with composite primary keys (PKFields)
+func (m *Model) DeleteByKey(key Key) (WriteResult, error) {
+	return m.DeleteByKeyContext(context.Background(), key)
+}
+
+// DeleteByKeyContext is DeleteByKey with a context
+func (m *Model) DeleteByKeyContext(ctx context.Context, key Key) (WriteResult, error) {
+	if m == nil {
+		return WriteResult{}, errors.New("cannot perform action: DeleteByKey() on nil model")
+	}
+
+	return m.withHooks(ctx, &HookContext{Event: BeforeDelete, ID: m.keyText(key), Key: key}, func(tm *Model, fields []SQLField) (WriteResult, error) {
+		return tm.delete(ctx, key)
+	})
+}
@@ -0,0 +1,125 @@
+package gomvc
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// linesModel returns the model of an order_lines table with a composite primary key and soft deletes
+func linesModel(t *testing.T) *Model {
+	t.Helper()
+	m := testModel(t)
+	_, err := m.DB.Exec(`CREATE TABLE order_lines (order_id INTEGER NOT NULL, line_no INTEGER NOT NULL, qty INTEGER NOT NULL,
+		deleted_at DATETIME, PRIMARY KEY (order_id, line_no))`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := &Model{PKFields: []string{"order_id", "line_no"}, SoftDeleteField: "deleted_at"}
+	if err := l.InitModel(m.DB, "order_lines", ""); err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+// line is the struct of the order_lines table
+type line struct {
+	OrderID int64 `db:"order_id"`
+	LineNo  int64 `db:"line_no"`
+	Qty     int   `db:"qty"`
+}
+
+func TestCompositeKeySQLite(t *testing.T) {
+	m := linesModel(t)
+	for _, l := range []line{{7, 1, 5}, {7, 2, 3}} {
+		if _, err := m.InsertStruct(l); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := m.UpdateStruct(&line{OrderID: 7, LineNo: 2, Qty: 4}); err != nil {
+		t.Fatal(err)
+	}
+	var lines []line
+	if err := m.NewQueryBuilder().OrderBy("line_no", "ASC").ScanInto(&lines); err != nil {
+		t.Fatal(err)
+	}
+	if want := []line{{7, 1, 5}, {7, 2, 4}}; !reflect.DeepEqual(lines, want) {
+		t.Errorf("lines = %v, want %v", lines, want)
+	}
+	if _, err := m.UpdateStruct(line{OrderID: 7, Qty: 1}); err == nil {
+		t.Error("UpdateStruct without line_no succeeded")
+	}
+	if _, err := m.Update([]SQLField{{FieldName: "qty", Value: 1}}, "7"); err != errCompositeKey {
+		t.Errorf("Update by id error = %v, want %v", err, errCompositeKey)
+	}
+
+	key := Key{"order_id": 7, "line_no": 1}
+	if _, err := m.DeleteByKey(key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.FindByKey(key); err != ErrRecordNotFound {
+		t.Errorf("FindByKey of a soft deleted line error = %v, want %v", err, ErrRecordNotFound)
+	}
+	if res, err := m.RestoreByKey(key); err != nil || res.RowsAffected != 1 {
+		t.Errorf("RestoreByKey = %+v, %v, want 1 row", res, err)
+	}
+	if _, err := m.FindByKey(key); err != nil {
+		t.Errorf("FindByKey of a restored line error = %v", err)
+	}
+	if _, err := m.Restore("7"); err != errCompositeKey {
+		t.Errorf("Restore by id error = %v, want %v", err, errCompositeKey)
+	}
+
+	if _, err := m.ForceDeleteByKey(key); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := m.NewQueryBuilder().WithTrashed().Count(); err != nil || n != 1 {
+		t.Errorf("lines after ForceDeleteByKey = %d, %v, want 1", n, err)
+	}
+	if _, err := m.ForceDeleteByKey(Key{"order_id": 7}); err == nil {
+		t.Error("ForceDeleteByKey with a partial key succeeded")
+	}
+}
+
+func TestInsertKey(t *testing.T) {
+	cars := testModel(t)
+	res := WriteResult{LastInsertId: 9}
+
+	if got := insertKey(cars, []SQLField{{FieldName: "name", Value: "ford"}}, res); !reflect.DeepEqual(got, Key{"id": int64(9)}) {
+		t.Errorf("auto increment key = %v, want id 9", got)
+	}
+	if got := insertKey(cars, []SQLField{{FieldName: "id", Value: "4"}}, res); !reflect.DeepEqual(got, Key{"id": "4"}) {
+		t.Errorf("posted key = %v, want id 4", got)
+	}
+
+	// key columns that are not auto increment never get LastInsertId
+	lines := linesModel(t)
+	if got := insertKey(lines, []SQLField{{FieldName: "order_id", Value: "7"}}, res); !reflect.DeepEqual(got, Key{"order_id": "7"}) {
+		t.Errorf("composite key = %v, want order_id 7 only", got)
+	}
+}
+
+func TestCreateActionCompositeKeySQLite(t *testing.T) {
+	m := linesModel(t)
+	c := testController(t, m, "/lines/create", "/lines/view/{order_id}/{line_no}", `{{.Error}}`)
+
+	// the key columns are not auto increment, the form posts them
+	if f := defaultGuarded(m); FindInSlice(f, "order_id") > -1 || FindInSlice(f, "line_no") > -1 {
+		t.Errorf("defaultGuarded = %v, want no key columns", f)
+	}
+
+	w := postForm(c, "/lines/create", url.Values{"order_id": {"7"}, "line_no": {"2"}, "qty": {"3"}})
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/lines/view/7/2" {
+		t.Errorf("create = %d %q, want redirect to /lines/view/7/2", w.Code, w.Header().Get("Location"))
+	}
+	r, err := m.FindByKey(Key{"order_id": 7, "line_no": 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Int("qty") != 3 {
+		t.Errorf("created line = %v, want qty 3", r)
+	}
+}
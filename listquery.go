@@ -0,0 +1,177 @@
+package gomvc
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// List query string grammar of the built-in view action (and NewQueryBuilderFromURL):
+//
+//	?sort=-price,name              order by price DESC, name ASC
+//	?filter[name]=ford             name = 'ford'
+//	?filter[price][gte]=100        price >= 100
+//	?filter[status][in]=a,b        status IN ('a', 'b')
+//	?filter[deleted][null]=true    deleted IS NULL (false: IS NOT NULL)
+//
+// Operators: eq, ne, gt, gte, lt, lte, like (value as given, with % wildcards), contains (%value%),
+// in, nin (comma separated lists), between (two comma separated values) and null.
+// Field names must be model columns, several filters are joined with AND.
+// The legacy ?filters={"name":"ford"} parameter is still supported (name LIKE %ford%).
+
+// filterKeyPattern matches filter[field] and filter[field][op]
+var filterKeyPattern = regexp.MustCompile(`^filter\[([^\[\]]+)\](?:\[([A-Za-z]+)\])?$`)
+
+// listOperators maps the query string operators to SQL operators
+var listOperators = map[string]string{
+	"eq": "=", "ne": "<>", "gt": ">", "gte": ">=", "lt": "<", "lte": "<=",
+	"like": "LIKE", "contains": "LIKE", "in": "IN", "nin": "NOT IN", "between": "BETWEEN", "null": "IS NULL",
+}
+
+// NewQueryBuilderFromURL returns a query builder with the filters and sort order of the URL query values,
+// see the list query grammar above. Unknown fields and operators return an error.
+func (m *Model) NewQueryBuilderFromURL(values url.Values) (*QueryBuilder, error) {
+	qb := m.recordsQueryBuilder()
+
+	filters, err := m.parseURLFilters(values)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range filters {
+		qb.addWhere(f, "AND")
+	}
+
+	terms, err := m.parseURLSort(values.Get("sort"))
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range terms {
+		qb.OrderBy(t.column, t.direction)
+	}
+
+	return qb, qb.Err()
+}
+
+// parseURLFilters parses the filter[field][op]=value parameters in a stable (sorted) order
+func (m *Model) parseURLFilters(values url.Values) ([]Filter, error) {
+	keys := make([]string, 0)
+	for k := range values {
+		if strings.HasPrefix(k, "filter[") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	filters := make([]Filter, 0)
+	for _, k := range keys {
+		match := filterKeyPattern.FindStringSubmatch(k)
+		if match == nil {
+			return nil, fmt.Errorf("invalid filter parameter: %q", k)
+		}
+
+		field, err := m.listField(match[1])
+		if err != nil {
+			return nil, err
+		}
+
+		opName := strings.ToLower(match[2])
+		if len(opName) == 0 {
+			opName = "eq"
+		}
+		op, ok := listOperators[opName]
+		if !ok {
+			return nil, fmt.Errorf("%w: filter operator %q", ErrInvalidOperator, match[2])
+		}
+
+		for _, v := range values[k] {
+			f := Filter{Field: field, Operator: op, Value: v}
+			switch opName {
+			case "contains":
+				f.Value = "%" + v + "%"
+			case "in", "nin":
+				f.Value = splitList(v)
+			case "between":
+				l := splitList(v)
+				if len(l) != 2 {
+					return nil, fmt.Errorf("filter %q needs two comma separated values", k)
+				}
+				f.Value = l
+			case "null":
+				switch strings.ToLower(v) {
+				case "", "1", "true":
+				case "0", "false":
+					f.Operator = "IS NOT NULL"
+				default:
+					return nil, fmt.Errorf("filter %q must be true or false", k)
+				}
+				f.Value = nil
+			}
+			filters = append(filters, f)
+		}
+	}
+
+	return filters, nil
+}
+
+// parseURLSort parses sort=-price,name, a leading - sorts descending
+func (m *Model) parseURLSort(value string) ([]orderTerm, error) {
+	terms := make([]orderTerm, 0)
+	if len(strings.TrimSpace(value)) == 0 {
+		return terms, nil
+	}
+
+	for _, s := range strings.Split(value, ",") {
+		s = strings.TrimSpace(s)
+		dir := "ASC"
+		if strings.HasPrefix(s, "-") {
+			s, dir = s[1:], "DESC"
+		} else if strings.HasPrefix(s, "+") {
+			s = s[1:]
+		}
+
+		field, err := m.listField(s)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, orderTerm{column: field, direction: dir})
+	}
+
+	return terms, nil
+}
+
+// listField validates a query string field name against the model columns and qualifies it with the table name
+func (m *Model) listField(name string) (string, error) {
+	if !validIdent(name, false) || FindInSlice(m.Fields, name) < 0 {
+		return "", fmt.Errorf("%w: unknown field %q", ErrInvalidIdentifier, name)
+	}
+	if strings.Contains(name, ".") {
+		return name, nil
+	}
+	return m.TableName + "." + name, nil
+}
+
+// legacyFilters converts the ?filters={"field":"value"} parameter to LIKE %value% filters
+func (m *Model) legacyFilters(params map[string][]interface{}) []Filter {
+	f := make([]Filter, 0)
+	for _, vv := range params["filters"] {
+		vvMap, _ := vv.(map[string]interface{})
+		for kkk, vvv := range vvMap {
+			if FindInSlice(m.Fields, kkk) > -1 && validIdent(kkk, false) {
+				f = append(f, Filter{Field: m.TableName + "." + kkk, Operator: "LIKE", Value: "%" + fmt.Sprint(vvv) + "%", Logic: "AND"})
+			}
+		}
+	}
+	return f
+}
+
+// splitList splits a comma separated list and trims the values
+func splitList(s string) []interface{} {
+	parts := strings.Split(s, ",")
+	l := make([]interface{}, len(parts))
+	for i, p := range parts {
+		l[i] = strings.TrimSpace(p)
+	}
+	return l
+}
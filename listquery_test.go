@@ -0,0 +1,123 @@
+package gomvc
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// listModel is a model for the list query tests, it is never connected
+func listModel() *Model {
+	return &Model{
+		Dialect:   PostgresDialect{},
+		TableName: "cars",
+		PKField:   "id",
+		Fields:    []string{"id", "name", "price", "status", "deleted", "brands.name"},
+	}
+}
+
+func TestParseURLFilters(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []Filter
+		err   error
+	}{
+		{"", []Filter{}, nil},
+		{"filter[name]=ford", []Filter{{Field: "cars.name", Operator: "=", Value: "ford"}}, nil},
+		{"filter[price][gte]=100", []Filter{{Field: "cars.price", Operator: ">=", Value: "100"}}, nil},
+		{"filter[name][ne]=a&filter[name][ne]=b", []Filter{{Field: "cars.name", Operator: "<>", Value: "a"}, {Field: "cars.name", Operator: "<>", Value: "b"}}, nil},
+		{"filter[name][like]=f%25", []Filter{{Field: "cars.name", Operator: "LIKE", Value: "f%"}}, nil},
+		{"filter[name][contains]=or", []Filter{{Field: "cars.name", Operator: "LIKE", Value: "%or%"}}, nil},
+		{"filter[status][in]=a, b", []Filter{{Field: "cars.status", Operator: "IN", Value: []interface{}{"a", "b"}}}, nil},
+		{"filter[status][nin]=a", []Filter{{Field: "cars.status", Operator: "NOT IN", Value: []interface{}{"a"}}}, nil},
+		{"filter[price][between]=1,5", []Filter{{Field: "cars.price", Operator: "BETWEEN", Value: []interface{}{"1", "5"}}}, nil},
+		{"filter[deleted][null]=true", []Filter{{Field: "cars.deleted", Operator: "IS NULL"}}, nil},
+		{"filter[deleted][null]=0", []Filter{{Field: "cars.deleted", Operator: "IS NOT NULL"}}, nil},
+		{"filter[brands.name]=bmw", []Filter{{Field: "brands.name", Operator: "=", Value: "bmw"}}, nil},
+		{"filter[price][gte]=1&filter[name]=x", []Filter{{Field: "cars.name", Operator: "=", Value: "x"}, {Field: "cars.price", Operator: ">=", Value: "1"}}, nil},
+		{"filter[secret]=1", nil, ErrInvalidIdentifier},
+		{"filter[name%3Bdrop]=1", nil, ErrInvalidIdentifier},
+		{"filter[name][regex]=x", nil, ErrInvalidOperator},
+		{"filter[price][between]=1", nil, nil},
+		{"filter[deleted][null]=maybe", nil, nil},
+		{"filter[name]]=x", nil, nil},
+	}
+
+	m := listModel()
+	for _, tt := range tests {
+		values, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := m.parseURLFilters(values)
+		if tt.want == nil {
+			if err == nil || (tt.err != nil && !errors.Is(err, tt.err)) {
+				t.Errorf("parseURLFilters(%q) error = %v, want %v", tt.query, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseURLFilters(%q) error = %v", tt.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseURLFilters(%q) = %#v, want %#v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseURLSort(t *testing.T) {
+	tests := []struct {
+		sort string
+		want []orderTerm
+		err  error
+	}{
+		{"", []orderTerm{}, nil},
+		{"name", []orderTerm{{"cars.name", "ASC"}}, nil},
+		{"-price,+name", []orderTerm{{"cars.price", "DESC"}, {"cars.name", "ASC"}}, nil},
+		{"brands.name", []orderTerm{{"brands.name", "ASC"}}, nil},
+		{"secret", nil, ErrInvalidIdentifier},
+		{"name desc", nil, ErrInvalidIdentifier},
+	}
+
+	m := listModel()
+	for _, tt := range tests {
+		got, err := m.parseURLSort(tt.sort)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("parseURLSort(%q) error = %v, want %v", tt.sort, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseURLSort(%q) = %v, want %v", tt.sort, got, tt.want)
+		}
+	}
+}
+
+func TestNewQueryBuilderFromURL(t *testing.T) {
+	values, err := url.ParseQuery("filter[name][contains]=fo&filter[price][between]=1,5&filter[deleted][null]=false&sort=-price,brands.name")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qb, err := listModel().NewQueryBuilderFromURL(values)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, args, err := qb.buildQuery()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantQ := `SELECT * FROM "cars" WHERE ("cars"."deleted" IS NOT NULL) AND ("cars"."name" LIKE $1) AND ("cars"."price" BETWEEN $2 AND $3) ORDER BY "cars"."price" DESC, "brands"."name" ASC`
+	if q != wantQ {
+		t.Errorf("query = %s, want %s", q, wantQ)
+	}
+	if wantArgs := []interface{}{"%fo%", "1", "5"}; !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %v, want %v", args, wantArgs)
+	}
+
+	if _, err := listModel().NewQueryBuilderFromURL(url.Values{"sort": {"password"}}); !errors.Is(err, ErrInvalidIdentifier) {
+		t.Errorf("unknown sort field error = %v, want %v", err, ErrInvalidIdentifier)
+	}
+}
@@ -0,0 +1,547 @@
+package gomvc
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Struct mapping uses the `db` struct tag to map columns to struct fields:
+//
+//	type Car struct {
+//		ID    int64   `db:"id"`
+//		Name  string  `db:"name"`
+//		Price float64 `db:"price,omitempty"`
+//		Parts []Part  `db:"parts"` // ResultStyleSubresult relation to table parts
+//		Notes string  `db:"-"`     // ignored
+//	}
+//
+// Fields without a tag are mapped to the lower case field name, embedded structs are flattened.
+// Struct (or slice of struct) fields are filled from the Subresult rows of the relation with the same table name.
+
+// structField is a mapped struct field
+type structField struct {
+	column    string
+	index     []int
+	omitEmpty bool
+	relation  bool
+}
+
+// structMap is the column mapping of a struct type
+type structMap struct {
+	fields []structField
+	byName map[string]*structField
+}
+
+var (
+	scannerType  = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType   = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	timeType     = reflect.TypeOf(time.Time{})
+	structMaps   sync.Map
+	errNilTarget = errors.New("gomvc: scan target must be a non nil pointer")
+)
+
+// getStructMap returns the (cached) mapping of a struct type
+func getStructMap(t reflect.Type) *structMap {
+	if sm, ok := structMaps.Load(t); ok {
+		return sm.(*structMap)
+	}
+
+	sm := &structMap{byName: make(map[string]*structField)}
+	buildStructMap(t, nil, sm)
+	for i := range sm.fields {
+		sm.byName[sm.fields[i].column] = &sm.fields[i]
+	}
+
+	structMaps.Store(t, sm)
+	return sm
+}
+
+// buildStructMap walks the struct fields, embedded structs are flattened
+func buildStructMap(t reflect.Type, parent []int, sm *structMap) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("db")
+		if tag == "-" {
+			continue
+		}
+
+		index := append(append([]int{}, parent...), i)
+
+		if f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct {
+			buildStructMap(f.Type, index, sm)
+			continue
+		}
+		if f.PkgPath != "" {
+			// unexported
+			continue
+		}
+
+		name, opts := tag, ""
+		if c := strings.Index(tag, ","); c > -1 {
+			name, opts = tag[:c], tag[c+1:]
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+
+		sm.fields = append(sm.fields, structField{
+			column:    name,
+			index:     index,
+			omitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+			relation:  isRelationType(f.Type),
+		})
+	}
+}
+
+// isRelationType returns true for struct, *struct and []struct fields that are not scanned as a single column
+func isRelationType(t reflect.Type) bool {
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || t == timeType {
+		return false
+	}
+	if reflect.PtrTo(t).Implements(scannerType) || t.Implements(valuerType) {
+		return false
+	}
+	return true
+}
+
+// Scan copies the row values into the struct pointed by dest, columns without a matching field are ignored.
+func (r *ResultRow) Scan(dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errNilTarget
+	}
+	v = v.Elem()
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("gomvc: cannot scan row into %s, target must be a pointer to struct", v.Type())
+	}
+
+	return r.scanStruct(v)
+}
+
+// scanStruct copies the row values and the related subresults into a struct value
+func (r *ResultRow) scanStruct(v reflect.Value) error {
+	sm := getStructMap(v.Type())
+
+	for i, col := range r.Fields {
+		sf, ok := sm.byName[col]
+		if !ok || sf.relation {
+			continue
+		}
+		fv := v.FieldByIndex(sf.index)
+		if err := setFieldValue(fv, r.Values[i]); err != nil {
+			return fmt.Errorf("gomvc: column [%s]: %w", col, err)
+		}
+	}
+
+	for _, sf := range sm.fields {
+		if !sf.relation {
+			continue
+		}
+		sub := make([]ResultRow, 0)
+		for _, sr := range r.Subresult {
+			if sr.TableName == sf.column {
+				sub = append(sub, sr)
+			}
+		}
+		if len(sub) == 0 {
+			continue
+		}
+		if err := scanRelation(v.FieldByIndex(sf.index), sub); err != nil {
+			return fmt.Errorf("gomvc: relation [%s]: %w", sf.column, err)
+		}
+	}
+
+	return nil
+}
+
+// scanRelation fills a struct, *struct or []struct field from subresult rows
+func scanRelation(fv reflect.Value, rows []ResultRow) error {
+	switch fv.Kind() {
+	case reflect.Slice:
+		s := reflect.MakeSlice(fv.Type(), 0, len(rows))
+		for i := range rows {
+			ev, err := newStructValue(fv.Type().Elem(), &rows[i])
+			if err != nil {
+				return err
+			}
+			s = reflect.Append(s, ev)
+		}
+		fv.Set(s)
+	default:
+		ev, err := newStructValue(fv.Type(), &rows[0])
+		if err != nil {
+			return err
+		}
+		fv.Set(ev)
+	}
+	return nil
+}
+
+// newStructValue scans a row into a new value of type t (struct or *struct)
+func newStructValue(t reflect.Type, row *ResultRow) (reflect.Value, error) {
+	isPtr := t.Kind() == reflect.Ptr
+	st := t
+	if isPtr {
+		st = t.Elem()
+	}
+
+	pv := reflect.New(st)
+	if err := row.scanStruct(pv.Elem()); err != nil {
+		return reflect.Value{}, err
+	}
+	if isPtr {
+		return pv, nil
+	}
+	return pv.Elem(), nil
+}
+
+// setFieldValue assigns a database value to a struct field converting compatible types
+func setFieldValue(fv reflect.Value, val interface{}) error {
+	if fv.CanAddr() && fv.Addr().Type().Implements(scannerType) {
+		return fv.Addr().Interface().(sql.Scanner).Scan(val)
+	}
+
+	if val == nil {
+		fv.Set(reflect.Zero(fv.Type()))
+		return nil
+	}
+
+	if fv.Kind() == reflect.Ptr {
+		nv := reflect.New(fv.Type().Elem())
+		if err := setFieldValue(nv.Elem(), val); err != nil {
+			return err
+		}
+		fv.Set(nv)
+		return nil
+	}
+
+	v := reflect.ValueOf(val)
+	if v.Type().AssignableTo(fv.Type()) {
+		fv.Set(v)
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		switch x := val.(type) {
+		case []byte:
+			fv.SetString(string(x))
+			return nil
+		case string:
+			fv.SetString(x)
+			return nil
+		}
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() == reflect.Uint8 {
+			switch x := val.(type) {
+			case string:
+				fv.SetBytes([]byte(x))
+				return nil
+			case []byte:
+				fv.SetBytes(append([]byte{}, x...))
+				return nil
+			}
+		}
+	case reflect.Bool:
+		switch {
+		case isIntKind(v.Kind()):
+			fv.SetBool(v.Int() != 0)
+			return nil
+		case isUintKind(v.Kind()):
+			fv.SetBool(v.Uint() != 0)
+			return nil
+		case v.Kind() == reflect.String:
+			b, err := strconv.ParseBool(v.String())
+			if err != nil {
+				return err
+			}
+			fv.SetBool(b)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if isIntKind(v.Kind()) || isUintKind(v.Kind()) || v.Kind() == reflect.Float32 || v.Kind() == reflect.Float64 {
+			return setNumber(fv, v)
+		}
+		if v.Kind() == reflect.String {
+			return setNumberFromString(fv, v.String())
+		}
+		if b, ok := val.([]byte); ok {
+			return setNumberFromString(fv, string(b))
+		}
+	}
+
+	if v.Type().ConvertibleTo(fv.Type()) && v.Kind() == fv.Kind() {
+		fv.Set(v.Convert(fv.Type()))
+		return nil
+	}
+
+	return fmt.Errorf("cannot assign value of type %T to field of type %s", val, fv.Type())
+}
+
+// setNumber assigns a number to an int, uint or float field, values out of the field range and
+// fractional values for integer fields are rejected
+func setNumber(fv reflect.Value, v reflect.Value) error {
+	overflow := fmt.Errorf("value %v overflows field of type %s", v.Interface(), fv.Type())
+
+	switch {
+	case isIntKind(fv.Kind()):
+		var n int64
+		switch {
+		case isIntKind(v.Kind()):
+			n = v.Int()
+		case isUintKind(v.Kind()):
+			if v.Uint() > math.MaxInt64 {
+				return overflow
+			}
+			n = int64(v.Uint())
+		default:
+			f := v.Float()
+			if f != math.Trunc(f) {
+				return fmt.Errorf("value %v has a fraction, field of type %s is an integer", f, fv.Type())
+			}
+			if f < math.MinInt64 || f >= math.MaxInt64 {
+				return overflow
+			}
+			n = int64(f)
+		}
+		if fv.OverflowInt(n) {
+			return overflow
+		}
+		fv.SetInt(n)
+	case isUintKind(fv.Kind()):
+		var n uint64
+		switch {
+		case isIntKind(v.Kind()):
+			if v.Int() < 0 {
+				return overflow
+			}
+			n = uint64(v.Int())
+		case isUintKind(v.Kind()):
+			n = v.Uint()
+		default:
+			f := v.Float()
+			if f != math.Trunc(f) {
+				return fmt.Errorf("value %v has a fraction, field of type %s is an integer", f, fv.Type())
+			}
+			if f < 0 || f >= math.MaxUint64 {
+				return overflow
+			}
+			n = uint64(f)
+		}
+		if fv.OverflowUint(n) {
+			return overflow
+		}
+		fv.SetUint(n)
+	default:
+		var f float64
+		switch {
+		case isIntKind(v.Kind()):
+			f = float64(v.Int())
+		case isUintKind(v.Kind()):
+			f = float64(v.Uint())
+		default:
+			f = v.Float()
+		}
+		if fv.OverflowFloat(f) {
+			return overflow
+		}
+		fv.SetFloat(f)
+	}
+	return nil
+}
+
+// setNumberFromString parses a numeric string into an int, uint or float field
+func setNumberFromString(fv reflect.Value, s string) error {
+	switch {
+	case isIntKind(fv.Kind()):
+		n, err := strconv.ParseInt(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetInt(n)
+	case isUintKind(fv.Kind()):
+		n, err := strconv.ParseUint(s, 10, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetUint(n)
+	default:
+		n, err := strconv.ParseFloat(s, fv.Type().Bits())
+		if err != nil {
+			return err
+		}
+		fv.SetFloat(n)
+	}
+	return nil
+}
+
+func isIntKind(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Int64
+}
+
+func isUintKind(k reflect.Kind) bool {
+	return k >= reflect.Uint && k <= reflect.Uintptr
+}
+
+// ScanInto executes the query and scans the results into dest,
+// dest is a pointer to a slice of structs (or struct pointers) or a pointer to a struct for the first row.
+func (qb *QueryBuilder) ScanInto(dest interface{}) error {
+	return qb.ScanIntoContext(context.Background(), dest)
+}
+
+// ScanIntoContext is ScanInto with a context
+func (qb *QueryBuilder) ScanIntoContext(ctx context.Context, dest interface{}) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return errNilTarget
+	}
+	v = v.Elem()
+
+	switch v.Kind() {
+	case reflect.Struct:
+		row, err := qb.FirstContext(ctx)
+		if err != nil {
+			return err
+		}
+		return row.Scan(dest)
+	case reflect.Slice:
+		et := v.Type().Elem()
+		if et.Kind() != reflect.Struct && !(et.Kind() == reflect.Ptr && et.Elem().Kind() == reflect.Struct) {
+			return fmt.Errorf("gomvc: cannot scan into %s, slice elements must be structs or struct pointers", v.Type())
+		}
+
+		rows, err := qb.ExecuteContext(ctx)
+		if err != nil {
+			return err
+		}
+
+		s := reflect.MakeSlice(v.Type(), 0, len(rows))
+		for i := range rows {
+			ev, err := newStructValue(et, &rows[i])
+			if err != nil {
+				return err
+			}
+			s = reflect.Append(s, ev)
+		}
+		v.Set(s)
+		return nil
+	}
+
+	return fmt.Errorf("gomvc: cannot scan into %s, target must be a pointer to struct or slice", v.Type())
+}
+
+// structFields builds the SQLField list of a struct for the model columns, the non zero primary key
+// fields (every PKFields column) are returned separately as key, relation fields are skipped.
+func (m *Model) structFields(src interface{}) ([]SQLField, Key, error) {
+	v := reflect.ValueOf(src)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, nil, errNilTarget
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("gomvc: expected struct, got %s", v.Type())
+	}
+
+	fields := make([]SQLField, 0)
+	key := Key{}
+	keyFields := m.KeyFields()
+
+	for _, sf := range getStructMap(v.Type()).fields {
+		if sf.relation {
+			continue
+		}
+		if len(m.Fields) > 0 && FindInSlice(m.Fields, sf.column) == -1 {
+			continue
+		}
+
+		fv := v.FieldByIndex(sf.index)
+		if FindInSlice(keyFields, sf.column) > -1 {
+			if !fv.IsZero() {
+				key[sf.column] = fv.Interface()
+			}
+			continue
+		}
+		if sf.omitEmpty && fv.IsZero() {
+			continue
+		}
+
+		var val interface{}
+		if fv.Kind() == reflect.Ptr {
+			if !fv.IsNil() {
+				val = fv.Elem().Interface()
+			}
+		} else {
+			val = fv.Interface()
+		}
+
+		fields = append(fields, SQLField{FieldName: sf.column, Value: val})
+	}
+
+	return fields, key, nil
+}
+
+// InsertStruct inserts a struct as a new record, a zero primary key field is left to the database (auto increment)
+func (m *Model) InsertStruct(src interface{}) (WriteResult, error) {
+	return m.InsertStructContext(context.Background(), src)
+}
+
+// InsertStructContext is InsertStruct with a context
+func (m *Model) InsertStructContext(ctx context.Context, src interface{}) (WriteResult, error) {
+	if m == nil {
+		return WriteResult{}, errors.New("cannot perform action: InsertStruct() on nil model")
+	}
+
+	fields, key, err := m.structFields(src)
+	if err != nil {
+		return WriteResult{}, err
+	}
+	keyFields := make([]SQLField, 0, len(key))
+	for _, f := range m.KeyFields() {
+		if v, ok := key[f]; ok {
+			keyFields = append(keyFields, SQLField{FieldName: f, Value: v})
+		}
+	}
+
+	return m.InsertContext(ctx, append(keyFields, fields...))
+}
+
+// UpdateStruct updates the record identified by the struct primary key fields (every PKFields column)
+func (m *Model) UpdateStruct(src interface{}) (WriteResult, error) {
+	return m.UpdateStructContext(context.Background(), src)
+}
+
+// UpdateStructContext is UpdateStruct with a context
+func (m *Model) UpdateStructContext(ctx context.Context, src interface{}) (WriteResult, error) {
+	if m == nil {
+		return WriteResult{}, errors.New("cannot perform action: UpdateStruct() on nil model")
+	}
+
+	fields, key, err := m.structFields(src)
+	if err != nil {
+		return WriteResult{}, err
+	}
+	if kf := m.KeyFields(); len(kf) == 0 || len(key) != len(kf) {
+		return WriteResult{}, errors.New("gomvc: UpdateStruct needs non zero primary key fields [" + strings.Join(kf, ", ") + "]")
+	}
+
+	return m.UpdateByKeyContext(ctx, fields, key)
+}
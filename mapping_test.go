@@ -0,0 +1,122 @@
+package gomvc
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSetFieldValue(t *testing.T) {
+	str := "x"
+	tests := []struct {
+		name    string
+		dest    interface{} // pointer to the field
+		val     interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{"int from int64", new(int), int64(42), 42, false},
+		{"int8 overflow", new(int8), int64(300), nil, true},
+		{"int from whole float", new(int), float64(3), 3, false},
+		{"int from fractional float", new(int), float64(2.5), nil, true},
+		{"int64 from big float", new(int64), float64(1e19), nil, true},
+		{"int64 from uint64", new(int64), uint64(7), int64(7), false},
+		{"int64 from big uint64", new(int64), uint64(math.MaxUint64), nil, true},
+		{"uint from negative int", new(uint), int64(-1), nil, true},
+		{"uint8 overflow", new(uint8), uint64(256), nil, true},
+		{"uint16 from float", new(uint16), float64(65535), uint16(65535), false},
+		{"float32 overflow", new(float32), float64(1e300), nil, true},
+		{"float32 from float64", new(float32), float64(1.5), float32(1.5), false},
+		{"float64 from int64", new(float64), int64(-3), float64(-3), false},
+		{"int from text", new(int), []byte("12"), 12, false},
+		{"int from invalid text", new(int), "1x", nil, true},
+		{"float from decimal", new(float64), Decimal("10.25"), 10.25, false},
+		{"string from bytes", new(string), []byte("ford"), "ford", false},
+		{"bytes from string", new([]byte), "ab", []byte("ab"), false},
+		{"bool from int", new(bool), int64(1), true, false},
+		{"bool from text", new(bool), "false", false, false},
+		{"pointer from value", new(*string), "x", &str, false},
+		{"pointer from NULL", new(*string), nil, (*string)(nil), false},
+		{"string from int", new(string), int64(1), nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fv := reflect.ValueOf(tt.dest).Elem()
+			err := setFieldValue(fv, tt.val)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := fv.Interface(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+// car is the struct of the cars test table
+type car struct {
+	ID      int64   `db:"id"`
+	Name    string  `db:"name"`
+	Price   *string `db:"price,omitempty"`
+	Version int     `db:"version,omitempty"`
+	Notes   string  `db:"-"`
+}
+
+func TestStructMappingSQLite(t *testing.T) {
+	m := testModel(t)
+
+	price := "12.50"
+	res, err := m.InsertStruct(&car{Name: "ford", Price: &price, Notes: "not a column"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.LastInsertId != 1 {
+		t.Fatalf("LastInsertId = %d, want 1", res.LastInsertId)
+	}
+	if _, err := m.InsertStruct(car{ID: 5, Name: "bmw"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var cars []car
+	if err := m.NewQueryBuilder().OrderBy("id", "ASC").ScanInto(&cars); err != nil {
+		t.Fatal(err)
+	}
+	if len(cars) != 2 || cars[0].Name != "ford" || cars[0].Price == nil || *cars[0].Price != "12.5" ||
+		cars[1].ID != 5 || cars[1].Price != nil || cars[1].Version != 1 {
+		t.Fatalf("ScanInto = %+v", cars)
+	}
+
+	cars[1].Name = "BMW"
+	if _, err := m.UpdateStruct(&cars[1]); err != nil {
+		t.Fatal(err)
+	}
+	var one car
+	if err := m.NewQueryBuilder().Where("id", "=", 5).ScanInto(&one); err != nil {
+		t.Fatal(err)
+	}
+	if one.Name != "BMW" {
+		t.Errorf("name after UpdateStruct = %q, want BMW", one.Name)
+	}
+
+	if _, err := m.UpdateStruct(car{Name: "no id"}); err == nil {
+		t.Error("UpdateStruct without primary key succeeded")
+	}
+
+	// price 12.5 does not fit an integer field
+	var wrong []struct {
+		Price int `db:"price"`
+	}
+	err = m.NewQueryBuilder().Where("id", "=", 1).ScanInto(&wrong)
+	if err == nil || !strings.Contains(err.Error(), "[price]") {
+		t.Errorf("ScanInto of 12.5 into int error = %v, want an error naming price", err)
+	}
+
+	if err := m.NewQueryBuilder().ScanInto(car{}); err != errNilTarget {
+		t.Errorf("ScanInto(non pointer) error = %v, want %v", err, errNilTarget)
+	}
+}
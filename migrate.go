@@ -0,0 +1,527 @@
+package gomvc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migrations are numbered SQL files in a directory (or embed.FS):
+//
+//	0001_create_users.up.sql
+//	0001_create_users.down.sql
+//	0002_add_products.up.sql
+//
+// or Go functions registered with RegisterMigration. Applied versions are stored in the schema_migrations table.
+// Every migration runs in its own transaction, note that MySql commits DDL statements implicitly.
+
+// MigrationFunc is a Go migration step
+type MigrationFunc func(ctx context.Context, tx *Tx) error
+
+// Migration is a versioned schema change with an up and an optional down step
+type Migration struct {
+	Version int64
+	Name    string
+	UpSQL   string
+	DownSQL string
+	Up      MigrationFunc
+	Down    MigrationFunc
+}
+
+// MigrationStatus is the state of a migration returned by Migrator.Status
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies and rolls back migrations
+type Migrator struct {
+	DB    *sql.DB
+	FS    fs.FS
+	Table string
+
+	migrations []Migration
+}
+
+// registeredMigrations are the Go migrations added with RegisterMigration
+var registeredMigrations []Migration
+
+// RegisterMigration registers a Go migration used by every Migrator
+func RegisterMigration(version int64, name string, up MigrationFunc, down MigrationFunc) {
+	registeredMigrations = append(registeredMigrations, Migration{Version: version, Name: name, Up: up, Down: down})
+}
+
+// NewMigrator creates a migrator for the migration files of fsys, fsys can be nil to use only Go migrations
+func NewMigrator(db *sql.DB, fsys fs.FS) *Migrator {
+	return &Migrator{DB: db, FS: fsys, Table: "schema_migrations"}
+}
+
+// Migrate applies all pending migrations found in dir
+func Migrate(db *sql.DB, dir string) error {
+	_, err := NewMigrator(db, os.DirFS(dir)).Migrate(context.Background())
+	return err
+}
+
+// Register adds a Go migration to this migrator only
+func (mg *Migrator) Register(version int64, name string, up MigrationFunc, down MigrationFunc) {
+	mg.migrations = append(mg.migrations, Migration{Version: version, Name: name, Up: up, Down: down})
+}
+
+// Migrate applies all pending migrations in version order and returns the applied migrations
+func (mg *Migrator) Migrate(ctx context.Context) ([]Migration, error) {
+	all, err := mg.load()
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := mg.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	applied, err := mg.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make([]Migration, 0)
+	for _, mgr := range all {
+		if _, ok := applied[mgr.Version]; ok {
+			continue
+		}
+		if mgr.Up == nil && strings.TrimSpace(mgr.UpSQL) == "" {
+			return done, fmt.Errorf("migration %d (%s) has no up step", mgr.Version, mgr.Name)
+		}
+
+		InfoMessage("Applying migration: " + strconv.FormatInt(mgr.Version, 10) + " " + mgr.Name)
+		err := WithTx(ctx, mg.DB, func(tx *Tx) error {
+			if err := runMigrationStep(ctx, tx, mgr.Up, mgr.UpSQL); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, "INSERT INTO "+tx.dialect.QuoteIdent(mg.table())+" (version, name, applied_at) VALUES (?, ?, ?)",
+				mgr.Version, mgr.Name, time.Now().UTC())
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d (%s) failed: %w", mgr.Version, mgr.Name, err)
+		}
+		done = append(done, mgr)
+	}
+
+	return done, nil
+}
+
+// Rollback reverts the last n applied migrations
+func (mg *Migrator) Rollback(ctx context.Context, n int) ([]Migration, error) {
+	all, err := mg.load()
+	if err != nil {
+		return nil, err
+	}
+
+	unlock, err := mg.lock(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	applied, err := mg.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]int64, 0, len(applied))
+	for v := range applied {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+
+	byVersion := make(map[int64]Migration)
+	for _, mgr := range all {
+		byVersion[mgr.Version] = mgr
+	}
+
+	done := make([]Migration, 0)
+	for i := 0; i < n && i < len(versions); i++ {
+		mgr, ok := byVersion[versions[i]]
+		if !ok || (mgr.Down == nil && strings.TrimSpace(mgr.DownSQL) == "") {
+			return done, fmt.Errorf("migration %d has no down step", versions[i])
+		}
+
+		InfoMessage("Rolling back migration: " + strconv.FormatInt(mgr.Version, 10) + " " + mgr.Name)
+		err := WithTx(ctx, mg.DB, func(tx *Tx) error {
+			if err := runMigrationStep(ctx, tx, mgr.Down, mgr.DownSQL); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, "DELETE FROM "+tx.dialect.QuoteIdent(mg.table())+" WHERE version = ?", mgr.Version)
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("rollback of migration %d (%s) failed: %w", mgr.Version, mgr.Name, err)
+		}
+		done = append(done, mgr)
+	}
+
+	return done, nil
+}
+
+// Status returns all known and applied migrations in version order
+func (mg *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	all, err := mg.load()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := mg.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	applied, err := mg.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	st := make([]MigrationStatus, 0, len(all))
+	for _, mgr := range all {
+		s := MigrationStatus{Version: mgr.Version, Name: mgr.Name}
+		if a, ok := applied[mgr.Version]; ok {
+			s.Applied = true
+			s.AppliedAt = a.AppliedAt
+			delete(applied, mgr.Version)
+		}
+		st = append(st, s)
+	}
+	// Applied versions without source (deleted files)
+	for _, a := range applied {
+		st = append(st, a)
+	}
+	sort.Slice(st, func(i, j int) bool { return st[i].Version < st[j].Version })
+
+	return st, nil
+}
+
+// table returns the tracking table name
+func (mg *Migrator) table() string {
+	if len(mg.Table) == 0 {
+		return "schema_migrations"
+	}
+	return mg.Table
+}
+
+// load reads the migration files and merges them with the Go migrations
+func (mg *Migrator) load() ([]Migration, error) {
+	byVersion := make(map[int64]*Migration)
+
+	add := func(m Migration) error {
+		cur, ok := byVersion[m.Version]
+		if !ok {
+			mm := m
+			byVersion[m.Version] = &mm
+			return nil
+		}
+		if cur.Name != m.Name {
+			return fmt.Errorf("duplicate migration version %d (%s, %s)", m.Version, cur.Name, m.Name)
+		}
+		if len(m.UpSQL) > 0 {
+			cur.UpSQL = m.UpSQL
+		}
+		if len(m.DownSQL) > 0 {
+			cur.DownSQL = m.DownSQL
+		}
+		if m.Up != nil {
+			cur.Up = m.Up
+		}
+		if m.Down != nil {
+			cur.Down = m.Down
+		}
+		return nil
+	}
+
+	if mg.FS != nil {
+		files, err := fs.Glob(mg.FS, "*.sql")
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			m, isUp, err := parseMigrationFileName(path.Base(f))
+			if err != nil {
+				return nil, err
+			}
+			b, err := fs.ReadFile(mg.FS, f)
+			if err != nil {
+				return nil, err
+			}
+			if isUp {
+				m.UpSQL = string(b)
+			} else {
+				m.DownSQL = string(b)
+			}
+			if err := add(m); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, list := range [][]Migration{registeredMigrations, mg.migrations} {
+		for _, m := range list {
+			if err := add(m); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	all := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		all = append(all, *m)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+
+	return all, nil
+}
+
+// parseMigrationFileName parses <version>_<name>.(up|down).sql
+func parseMigrationFileName(name string) (Migration, bool, error) {
+	var isUp bool
+	base := name
+	switch {
+	case strings.HasSuffix(name, ".up.sql"):
+		isUp = true
+		base = strings.TrimSuffix(name, ".up.sql")
+	case strings.HasSuffix(name, ".down.sql"):
+		base = strings.TrimSuffix(name, ".down.sql")
+	default:
+		return Migration{}, false, errors.New("invalid migration file name (expected .up.sql or .down.sql): " + name)
+	}
+
+	parts := strings.SplitN(base, "_", 2)
+	v, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return Migration{}, false, errors.New("invalid migration version in file name: " + name)
+	}
+
+	m := Migration{Version: v}
+	if len(parts) > 1 {
+		m.Name = parts[1]
+	}
+
+	return m, isUp, nil
+}
+
+// runMigrationStep executes a Go step or the SQL statements of a migration
+func runMigrationStep(ctx context.Context, tx *Tx, fn MigrationFunc, q string) error {
+	if fn != nil {
+		return fn(ctx, tx)
+	}
+	for _, stmt := range splitStatements(tx.dialect, q) {
+		if _, err := tx.tx.ExecContext(ctx, stmt); err != nil {
+			InfoMessage(stmt)
+			return err
+		}
+	}
+	return nil
+}
+
+// splitStatements splits an SQL script on ; outside quotes, comments (-- and /* */) and PostgreSQL dollar quoted
+// bodies ($$ ... $$ or $tag$ ... $tag$). Line comments are removed, block comments are kept (MySql /*! */ hints).
+// For MySql a backslash escapes the next character in quoted strings ('it\'s').
+func splitStatements(d Dialect, q string) []string {
+	_, backslash := d.(MySQLDialect)
+	stmts := make([]string, 0)
+	var sb strings.Builder
+	var quote byte
+
+	flush := func() {
+		if s := strings.TrimSpace(sb.String()); len(s) > 0 {
+			stmts = append(stmts, s)
+		}
+		sb.Reset()
+	}
+
+	for i := 0; i < len(q); i++ {
+		ch := q[i]
+		switch {
+		case quote != 0:
+			if backslash && ch == '\\' && quote != '`' && i+1 < len(q) {
+				sb.WriteByte(ch)
+				i++
+				ch = q[i]
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+		case ch == '-' && i+1 < len(q) && q[i+1] == '-':
+			// line comment
+			for i < len(q) && q[i] != '\n' {
+				i++
+			}
+			sb.WriteByte('\n')
+			continue
+		case ch == '/' && i+1 < len(q) && q[i+1] == '*':
+			// block comment
+			end := strings.Index(q[i+2:], "*/")
+			if end == -1 {
+				end = len(q)
+			} else {
+				end += i + 4
+			}
+			sb.WriteString(q[i:end])
+			i = end - 1
+			continue
+		case ch == '$':
+			// dollar quoted body
+			if tag := dollarTag(q[i:]); len(tag) > 0 {
+				end := strings.Index(q[i+len(tag):], tag)
+				if end == -1 {
+					end = len(q)
+				} else {
+					end += i + 2*len(tag)
+				}
+				sb.WriteString(q[i:end])
+				i = end - 1
+				continue
+			}
+		case ch == ';':
+			flush()
+			continue
+		}
+		sb.WriteByte(ch)
+	}
+	flush()
+
+	return stmts
+}
+
+// dollarTag returns the dollar quote tag at the start of q, e.g. $$ or $body$, empty when q does not start with one
+func dollarTag(q string) string {
+	for i := 1; i < len(q); i++ {
+		ch := q[i]
+		if ch == '$' {
+			return q[:i+1]
+		}
+		// the tag is an identifier, $1 is a placeholder
+		if !(ch == '_' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (i > 1 && ch >= '0' && ch <= '9')) {
+			return ""
+		}
+	}
+	return ""
+}
+
+// ensureTable creates the tracking table
+func (mg *Migrator) ensureTable(ctx context.Context) error {
+	d := GetDialect(mg.DB)
+	_, err := mg.DB.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+d.QuoteIdent(mg.table())+
+		" (version BIGINT NOT NULL PRIMARY KEY, name VARCHAR(255) NOT NULL, applied_at TIMESTAMP NOT NULL)")
+	return err
+}
+
+// applied returns the applied migrations by version
+func (mg *Migrator) applied(ctx context.Context) (map[int64]MigrationStatus, error) {
+	d := GetDialect(mg.DB)
+	r, err := mg.DB.QueryContext(ctx, "SELECT version, name, applied_at FROM "+d.QuoteIdent(mg.table()))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	applied := make(map[int64]MigrationStatus)
+	for r.Next() {
+		var s MigrationStatus
+		var at interface{}
+		if err := r.Scan(&s.Version, &s.Name, &at); err != nil {
+			return nil, err
+		}
+		if t, ok := at.(time.Time); ok {
+			s.AppliedAt = t
+		}
+		s.Applied = true
+		applied[s.Version] = s
+	}
+
+	return applied, r.Err()
+}
+
+// lock takes the migration lock so two app instances don't migrate at the same time,
+// MySql and PostgreSQL use advisory locks, other databases a lock table.
+func (mg *Migrator) lock(ctx context.Context) (func(), error) {
+	if mg.DB == nil {
+		return nil, errors.New("migrator has no database")
+	}
+
+	if err := mg.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	d := GetDialect(mg.DB)
+	name := "gomvc_" + mg.table()
+
+	switch d.(type) {
+	case MySQLDialect, PostgresDialect:
+		conn, err := mg.DB.Conn(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var lockQ, unlockQ string
+		var key interface{}
+		if _, ok := d.(MySQLDialect); ok {
+			lockQ, unlockQ, key = "SELECT GET_LOCK(?, 60)", "SELECT RELEASE_LOCK(?)", name
+		} else {
+			h := fnv.New64a()
+			h.Write([]byte(name))
+			lockQ, unlockQ, key = "SELECT pg_advisory_lock($1)", "SELECT pg_advisory_unlock($1)", int64(h.Sum64())
+		}
+
+		var res interface{}
+		if err := conn.QueryRowContext(ctx, lockQ, key).Scan(&res); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("cannot acquire migration lock: %w", err)
+		}
+		if _, ok := d.(MySQLDialect); ok && !lockAcquired(res) {
+			conn.Close()
+			return nil, errors.New("cannot acquire migration lock: timeout, another instance is migrating")
+		}
+
+		return func() {
+			var res interface{}
+			conn.QueryRowContext(context.Background(), unlockQ, key).Scan(&res)
+			conn.Close()
+		}, nil
+	}
+
+	lockTable := d.QuoteIdent(mg.table() + "_lock")
+	if _, err := mg.DB.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+lockTable+" (id INTEGER NOT NULL PRIMARY KEY)"); err != nil {
+		return nil, err
+	}
+	if _, err := mg.DB.ExecContext(ctx, "INSERT INTO "+lockTable+" (id) VALUES (1)"); err != nil {
+		return nil, fmt.Errorf("cannot acquire migration lock, another instance is migrating (or delete the row of %s): %w", lockTable, err)
+	}
+
+	return func() {
+		mg.DB.ExecContext(context.Background(), "DELETE FROM "+lockTable)
+	}, nil
+}
+
+// lockAcquired reports whether a GET_LOCK result is 1, 0 is a timeout and NULL an error
+func lockAcquired(res interface{}) bool {
+	switch v := res.(type) {
+	case int64:
+		return v == 1
+	case []byte:
+		return string(v) == "1"
+	case string:
+		return v == "1"
+	}
+	return false
+}
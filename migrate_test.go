@@ -0,0 +1,129 @@
+package gomvc
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name string
+		d    Dialect
+		q    string
+		want []string
+	}{
+		{"single", PostgresDialect{}, "SELECT 1", []string{"SELECT 1"}},
+		{"several", PostgresDialect{}, "CREATE TABLE a (id INT);\nINSERT INTO a VALUES (1);\n", []string{"CREATE TABLE a (id INT)", "INSERT INTO a VALUES (1)"}},
+		{"empty statements", PostgresDialect{}, ";; SELECT 1 ;;", []string{"SELECT 1"}},
+		{"quoted", PostgresDialect{}, `INSERT INTO a VALUES ('x;y', "p;q", ` + "`c;d`" + `); SELECT 2`, []string{`INSERT INTO a VALUES ('x;y', "p;q", ` + "`c;d`" + `)`, "SELECT 2"}},
+		{"doubled quote", PostgresDialect{}, "SELECT 'it''s; fine'; SELECT 2", []string{"SELECT 'it''s; fine'", "SELECT 2"}},
+		{"line comment", PostgresDialect{}, "SELECT 1; -- comment; not a statement\nSELECT 2", []string{"SELECT 1", "SELECT 2"}},
+		{"block comment", PostgresDialect{}, "/* first; second */ SELECT 1; SELECT /* a;b */ 2", []string{"/* first; second */ SELECT 1", "SELECT /* a;b */ 2"}},
+		{"mysql hint", PostgresDialect{}, "/*!40101 SET NAMES utf8; */; SELECT 1", []string{"/*!40101 SET NAMES utf8; */", "SELECT 1"}},
+		{"unterminated block comment", PostgresDialect{}, "SELECT 1; /* a; b", []string{"SELECT 1", "/* a; b"}},
+		{
+			"dollar quoted",
+			PostgresDialect{},
+			"CREATE FUNCTION f() RETURNS trigger AS $$ BEGIN NEW.a := 1; RETURN NEW; END; $$ LANGUAGE plpgsql; SELECT 1",
+			[]string{"CREATE FUNCTION f() RETURNS trigger AS $$ BEGIN NEW.a := 1; RETURN NEW; END; $$ LANGUAGE plpgsql", "SELECT 1"},
+		},
+		{
+			"dollar quoted tag",
+			PostgresDialect{},
+			"DO $body$ BEGIN PERFORM 1; PERFORM '$$'; END $body$; SELECT 1",
+			[]string{"DO $body$ BEGIN PERFORM 1; PERFORM '$$'; END $body$", "SELECT 1"},
+		},
+		{"placeholder", PostgresDialect{}, "SELECT $1; SELECT $2", []string{"SELECT $1", "SELECT $2"}},
+		{"mysql backslash quote", MySQLDialect{}, `INSERT INTO a VALUES ('it\'s; ok', "a\"; b"); SELECT 2`, []string{`INSERT INTO a VALUES ('it\'s; ok', "a\"; b")`, "SELECT 2"}},
+		{"mysql backslash", MySQLDialect{}, `SELECT 'C:\\'; SELECT 2`, []string{`SELECT 'C:\\'`, "SELECT 2"}},
+		{"postgres backslash", PostgresDialect{}, `SELECT 'C:\'; SELECT 2`, []string{`SELECT 'C:\'`, "SELECT 2"}},
+		{"sqlite backslash", SQLiteDialect{}, `SELECT 'C:\'; SELECT 2`, []string{`SELECT 'C:\'`, "SELECT 2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitStatements(tt.d, tt.q); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitStatements(%q) = %q, want %q", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLockAcquired(t *testing.T) {
+	tests := []struct {
+		res  interface{}
+		want bool
+	}{
+		{int64(1), true},
+		{[]byte("1"), true},
+		{"1", true},
+		{int64(0), false},
+		{[]byte("0"), false},
+		{nil, false},
+		{[]byte{}, false},
+	}
+
+	for _, tt := range tests {
+		if got := lockAcquired(tt.res); got != tt.want {
+			t.Errorf("lockAcquired(%#v) = %v, want %v", tt.res, got, tt.want)
+		}
+	}
+}
+
+func TestMigratorSQLite(t *testing.T) {
+	InitHelpers(&AppConfig{})
+	db, err := ConnectDatabaseSQLite(filepath.Join(t.TempDir(), "migrate.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+
+	fsys := fstest.MapFS{
+		"0001_create_cars.up.sql":   {Data: []byte("CREATE TABLE cars (id INTEGER PRIMARY KEY, name TEXT);\nINSERT INTO cars (name) VALUES ('a;b');")},
+		"0001_create_cars.down.sql": {Data: []byte("DROP TABLE cars;")},
+		"0002_bad.up.sql":           {Data: []byte("INSERT INTO cars (name) VALUES ('kept?'); INSERT INTO missing VALUES (1);")},
+	}
+	mg := NewMigrator(db, fsys)
+	mg.Register(3, "go step", func(ctx context.Context, tx *Tx) error {
+		_, err := tx.Exec(ctx, "INSERT INTO cars (name) VALUES (?)", "go")
+		return err
+	}, func(ctx context.Context, tx *Tx) error {
+		_, err := tx.Exec(ctx, "DELETE FROM cars WHERE name = ?", "go")
+		return err
+	})
+
+	done, err := mg.Migrate(ctx)
+	if err == nil || len(done) != 1 || done[0].Version != 1 {
+		t.Fatalf("Migrate = %v, %v, want migration 1 applied and an error for 2", done, err)
+	}
+
+	// The failed migration is rolled back as a whole
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM cars").Scan(&n); err != nil || n != 1 {
+		t.Fatalf("cars = %d, %v, want 1 row", n, err)
+	}
+
+	delete(fsys, "0002_bad.up.sql")
+	if done, err = mg.Migrate(ctx); err != nil || len(done) != 1 || done[0].Version != 3 {
+		t.Fatalf("Migrate = %v, %v, want migration 3 applied", done, err)
+	}
+
+	status, err := mg.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(status) != 2 || !status[0].Applied || !status[1].Applied {
+		t.Errorf("Status = %+v, want 2 applied migrations", status)
+	}
+
+	if done, err = mg.Rollback(ctx, 2); err != nil || len(done) != 2 || done[0].Version != 3 {
+		t.Fatalf("Rollback = %v, %v, want migrations 3 and 1 rolled back", done, err)
+	}
+	if _, err := db.Exec("SELECT 1 FROM cars"); err == nil {
+		t.Error("table cars exists after the rollback")
+	}
+}
@@ -4,9 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"strconv"
+	"fmt"
 	"strings"
-	"time"
 )
 
 // JoinType, supported MySql join types INNER, LEFT, RIGHT
@@ -39,21 +38,46 @@ const (
 // Model is the model struct holding all the data and parameters for each model.
 type Model struct {
 	DB           *sql.DB
+	Dialect      Dialect
 	PKField      string
+	PKFields     []string // columns of a composite primary key, see Key and the ByKey methods
 	TableName    string
 	OrderString  string
 	Fields       []string
+	Columns      []Column
+	ForeignKeys  []ForeignKey
 	Labels       map[string]string
 	Relations    []Relation
 	DefaultQuery string
-	lastQuery    string
-	lastValues   []interface{}
+	// RequireRowsAffected makes Update / Delete return ErrRecordNotFound when no row matched
+	RequireRowsAffected bool
+	// CreatedAtField and UpdatedAtField are columns set to the current time by Insert (both) and Update (UpdatedAtField)
+	CreatedAtField string
+	UpdatedAtField string
+	// SoftDeleteField makes Delete set the column to the current time instead of deleting the row,
+	// soft deleted rows are excluded from queries, see WithTrashed, OnlyTrashed, Restore and ForceDelete
+	SoftDeleteField string
+	// VersionField enables optimistic locking: Insert sets the column to 1, Update increments it and when the
+	// fields have the version read with the record the update matches that version only, see ErrStaleRecord
+	VersionField string
+	// Fillable lists the only fields the built-in create / update actions bind from the posted form (all fields when empty),
+	// Guarded lists fields they never bind. The primary key, the timestamp / soft delete columns and the AuthObject
+	// password, hash code and expiration columns are guarded unless they are listed in Fillable.
+	Fillable   []string
+	Guarded    []string
+	trashed    trashedScope
+	hooks      map[HookEvent][]Hook
+	rules      map[string][]Rule
+	lastQuery  string
+	lastValues []interface{}
+	tx         *Tx
 }
 
 // ResultRow is the result coming from MySql database
 type ResultRow struct {
 	Values    []interface{}
 	Fields    []string
+	TableName string
 	pointers  []interface{}
 	Subresult []ResultRow
 }
@@ -63,14 +87,24 @@ type Relation struct {
 	Join          SQLJoin
 	Foreign_model Model
 	ResultStyle   ResultStyle
+	Type          RelationType
 }
 
-// SQLJoin the type of MySql Join used by Relation
+// SQLJoin the type of MySql Join used by Relation,
+// with a Pivot table the join goes through the pivot table (many-to-many)
 type SQLJoin struct {
 	Foreign_table string
 	Foreign_PK    string
 	KeyPair       SQLKeyPair
 	Join_type     JoinType
+	Pivot         SQLPivot
+}
+
+// SQLPivot is the pivot table of a many-to-many relation
+type SQLPivot struct {
+	Table      string // pivot table, e.g. post_tags
+	LocalKey   string // pivot column referencing the local key, e.g. post_id
+	ForeignKey string // pivot column referencing the foreign key, e.g. tag_id
 }
 
 // SQLTable the SQL table object
@@ -79,7 +113,8 @@ type SQLTable struct {
 	PKField   string
 }
 
-// SQLField the MySql table field object
+// SQLField the MySql table field object, a nil Value writes NULL.
+// Insert and Update only write the listed fields, leave a field out to keep its current value.
 type SQLField struct {
 	FieldName string
 	Value     interface{}
@@ -91,12 +126,33 @@ type SQLKeyPair struct {
 	ForeignKey string
 }
 
-// Filter is user to filter data in WHERE Clause MySql statement
+// WriteResult is the result of an INSERT, UPDATE or DELETE query
+type WriteResult struct {
+	LastInsertId int64
+	RowsAffected int64
+}
+
+// ErrRecordNotFound is returned by Update / Delete when RequireRowsAffected is set and no row matched
+var ErrRecordNotFound = errors.New("record not found")
+
+// Filter is user to filter data in WHERE Clause MySql statement.
+// A filter with Group is a nested condition group in parentheses, a filter with Raw is a raw SQL expression
+// with ? placeholders and the values in Value ([]interface{}), Field and Operator are ignored for both.
 type Filter struct {
 	Field    string
 	Operator string
 	Value    interface{}
 	Logic    string
+	Group    []Filter
+	Raw      string
+}
+
+// dialect returns the model dialect, the dialect registered for the model connection or the default dialect
+func (m *Model) dialect() Dialect {
+	if m.Dialect != nil {
+		return m.Dialect
+	}
+	return GetDialect(m.DB)
 }
 
 // Instance function returns the current model instance
@@ -117,31 +173,29 @@ func (r *ResultRow) GetFieldIndex(name string) int {
 
 // InitModel pass all initial parammeters to activate the model
 func (m *Model) InitModel(db *sql.DB, tableName string, PKField string) error {
+	return m.InitModelContext(context.Background(), db, tableName, PKField)
+}
+
+// InitModelContext is InitModel with a context
+func (m *Model) InitModelContext(ctx context.Context, db *sql.DB, tableName string, PKField string) error {
 	m.DB = db
 	m.TableName = tableName
 	m.PKField = PKField
 
-	var q = "SHOW COLUMNS FROM " + tableName
-	r, err := m.DB.Query(q)
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	cols, err := m.dialect().Columns(ctx, m.DB, tableName)
 	if err != nil {
 		return err
 	}
-	defer r.Close()
-
-	for r.Next() {
-		var rr ResultRow
-		rr.Values = make([]interface{}, 6)
-		rr.pointers = make([]interface{}, 6)
-
-		for i := 0; i < 6; i++ {
-			rr.pointers[i] = &rr.Values[i]
-		}
-
-		r.Scan(rr.pointers...)
+	m.Columns = cols
+	for _, c := range cols {
+		m.Fields = append(m.Fields, c.Name)
+	}
 
-		b := rr.Values[0].([]byte)
-		n := string(b)
-		m.Fields = append(m.Fields, n)
+	if m.ForeignKeys, err = m.dialect().ForeignKeys(ctx, m.DB, tableName); err != nil {
+		return err
 	}
 
 	if len(m.Relations) > 0 {
@@ -185,22 +239,34 @@ func (m *Model) Label(field string) string {
 
 // GetLastId is a function to get the last id from a Table/Model
 func (m *Model) GetLastId() (int64, error) {
+	return m.GetLastIdContext(context.Background())
+}
+
+// GetLastIdContext is GetLastId with a context
+func (m *Model) GetLastIdContext(ctx context.Context) (int64, error) {
 	if m == nil {
 		return 0, errors.New("cannot perform action: GetLastId() on nil model")
 	}
 
-	var q string
-	q, _ = BuildQuery(QueryTypeSelect,
+	q, values, err := buildQuery(m.dialect(), QueryTypeSelect,
 		[]SQLField{{FieldName: m.PKField}},
 		SQLTable{TableName: m.TableName, PKField: m.PKField},
-		[]SQLJoin{}, []Filter{}, "", "ORDER BY "+m.PKField+" DESC", 1)
+		[]SQLJoin{}, []Filter{}, "", "ORDER BY "+m.PKField+" DESC", 1, 0)
+	if err != nil {
+		return 0, err
+	}
 
-	r, err := m.DB.Query(q)
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	r, err := m.conn().QueryContext(ctx, q, values...)
 
 	if err != nil {
 		return 0, err
 	}
 
+	defer r.Close()
+
 	var id int64
 	r.Next()
 	err = r.Scan(&id)
@@ -213,6 +279,11 @@ func (m *Model) GetLastId() (int64, error) {
 
 // GetRecords is function to execute a query against a table/model with filters (WHERE filters)
 func (m *Model) GetRecords(filters []Filter, limit int64) ([]ResultRow, error) {
+	return m.GetRecordsContext(context.Background(), filters, limit)
+}
+
+// GetRecordsContext is GetRecords with a context, the query is cancelled when ctx is done
+func (m *Model) GetRecordsContext(ctx context.Context, filters []Filter, limit int64) ([]ResultRow, error) {
 	if m == nil {
 		return []ResultRow{}, errors.New("cannot perform action: GetRecords() on nil model")
 	}
@@ -220,6 +291,9 @@ func (m *Model) GetRecords(filters []Filter, limit int64) ([]ResultRow, error) {
 	var r *sql.Rows
 	var err error
 
+	qctx, cancel := queryContext(ctx)
+	defer cancel()
+
 	if len(m.DefaultQuery) == 0 {
 		j := make([]SQLJoin, 0)
 		if len(m.Relations) > 0 {
@@ -230,15 +304,22 @@ func (m *Model) GetRecords(filters []Filter, limit int64) ([]ResultRow, error) {
 			}
 		}
 
-		q, values := BuildQuery(QueryTypeSelect, []SQLField{{FieldName: "*"}},
+		if err := m.checkFilters(filters); err != nil {
+			return []ResultRow{}, err
+		}
+
+		q, values, err := buildQuery(m.dialect(), QueryTypeSelect, []SQLField{{FieldName: "*"}},
 			SQLTable{TableName: m.TableName, PKField: m.PKField},
-			j, filters, "", "", limit)
+			j, m.scopeFilters(filters, m.trashed), "", "", limit, 0)
+		if err != nil {
+			return []ResultRow{}, err
+		}
 
 		//fmt.Println("QUERY:" + q)
 		m.lastQuery = q
 		m.lastValues = values
 
-		r, err = m.DB.Query(q, values...)
+		r, err = m.conn().QueryContext(qctx, q, values...)
 		if err != nil {
 			InfoMessage(q)
 			return []ResultRow{}, err
@@ -246,13 +327,19 @@ func (m *Model) GetRecords(filters []Filter, limit int64) ([]ResultRow, error) {
 	} else {
 		m.lastQuery = m.DefaultQuery
 		m.lastValues = make([]interface{}, 0)
-		r, err = m.DB.Query(m.DefaultQuery)
+		r, err = m.conn().QueryContext(qctx, m.DefaultQuery)
 		if err != nil {
 			InfoMessage(m.DefaultQuery)
 			return []ResultRow{}, err
 		}
 	}
+	defer r.Close()
+
+	return m.scanRows(ctx, r)
+}
 
+// scanRows is a helper method to scan database rows into ResultRow slice
+func (m *Model) scanRows(ctx context.Context, r *sql.Rows) ([]ResultRow, error) {
 	typ, err := r.ColumnTypes()
 	if err != nil {
 		return []ResultRow{}, err
@@ -266,133 +353,84 @@ func (m *Model) GetRecords(filters []Filter, limit int64) ([]ResultRow, error) {
 	var rrr []ResultRow
 
 	for r.Next() {
-		var rr ResultRow
-		rr.Values = make([]interface{}, len(typ))
-		rr.pointers = make([]interface{}, len(typ))
-		rr.Fields = fld
-
-		for i := range typ {
-			rr.pointers[i] = &rr.Values[i]
-		}
-
-		err = r.Scan(rr.pointers...)
+		rr, err := scanRow(r, typ, fld, m.TableName)
 		if err != nil {
 			return []ResultRow{}, err
 		}
-
-		for i := range rr.Values {
-			val, err := constructField(typ[i], rr.Values[i])
-			if err != nil {
-				return []ResultRow{}, err
-			}
-			rr.Values[i] = val
-		}
-
-		if len(m.Relations) > 0 {
-			for _, relation := range m.Relations {
-				if relation.ResultStyle == ResultStyleSubresult {
-					//PKIndex := rr.GetFieldIndex(m.PKField)
-					PKIndex := rr.GetFieldIndex(relation.Join.KeyPair.LocalKey)
-					f := make([]Filter, 0)
-					//f = append(f, Filter{Field: relation.Join.Foreign_key, Operator: "=", Value: rr.Values[PKIndex]})
-					f = append(f, Filter{Field: relation.Join.KeyPair.ForeignKey, Operator: "=", Value: rr.Values[PKIndex]})
-					rel_rr, err := relation.Foreign_model.GetRecords(f, 0)
-					if err != nil {
-						return []ResultRow{}, err
-					}
-					rr.Subresult = append(rr.Subresult, rel_rr...)
-				}
-			}
-		}
-
 		rrr = append(rrr, rr)
 	}
-
-	r.Close()
-
-	return rrr, nil
-}
-
-// scanRows is a helper method to scan database rows into ResultRow slice
-func (m *Model) scanRows(r *sql.Rows) ([]ResultRow, error) {
-	typ, err := r.ColumnTypes()
-	if err != nil {
+	if err := r.Err(); err != nil {
 		return []ResultRow{}, err
 	}
 
-	fld, err := r.Columns()
-	if err != nil {
+	// Subresult relations are loaded with one query per relation for all rows
+	if err := m.loadRelations(ctx, rrr); err != nil {
 		return []ResultRow{}, err
 	}
 
-	var rrr []ResultRow
+	if m.hasHooks(AfterFind) && len(rrr) > 0 {
+		hc := &HookContext{Model: m, Event: AfterFind, Rows: rrr}
+		if err := m.runHooks(ctx, hc); err != nil {
+			return []ResultRow{}, err
+		}
+		rrr = hc.Rows
+	}
 
-	for r.Next() {
-		var rr ResultRow
-		rr.Values = make([]interface{}, len(typ))
-		rr.pointers = make([]interface{}, len(typ))
-		rr.Fields = fld
+	return rrr, nil
+}
 
-		for i := range typ {
-			rr.pointers[i] = &rr.Values[i]
-		}
+// scanRow scans the current row and converts the values with constructField
+func scanRow(r *sql.Rows, typ []*sql.ColumnType, fld []string, tableName string) (ResultRow, error) {
+	var rr ResultRow
+	rr.Values = make([]interface{}, len(typ))
+	rr.pointers = make([]interface{}, len(typ))
+	rr.Fields = fld
+	rr.TableName = tableName
 
-		err = r.Scan(rr.pointers...)
-		if err != nil {
-			return []ResultRow{}, err
-		}
+	for i := range typ {
+		rr.pointers[i] = &rr.Values[i]
+	}
 
-		for i := range rr.Values {
-			val, err := constructField(typ[i], rr.Values[i])
-			if err != nil {
-				return []ResultRow{}, err
-			}
-			rr.Values[i] = val
-		}
+	if err := r.Scan(rr.pointers...); err != nil {
+		return ResultRow{}, err
+	}
 
-		// Handle relations if configured
-		if len(m.Relations) > 0 {
-			for _, relation := range m.Relations {
-				if relation.ResultStyle == ResultStyleSubresult {
-					PKIndex := rr.GetFieldIndex(relation.Join.KeyPair.LocalKey)
-					if PKIndex >= 0 && PKIndex < len(rr.Values) {
-						f := make([]Filter, 0)
-						f = append(f, Filter{
-							Field:    relation.Join.KeyPair.ForeignKey,
-							Operator: "=",
-							Value:    rr.Values[PKIndex],
-						})
-						rel_rr, err := relation.Foreign_model.GetRecords(f, 0)
-						if err != nil {
-							return []ResultRow{}, err
-						}
-						rr.Subresult = append(rr.Subresult, rel_rr...)
-					}
-				}
-			}
+	for i := range rr.Values {
+		val, err := constructField(typ[i], rr.Values[i])
+		if err != nil {
+			return ResultRow{}, err
 		}
-
-		rrr = append(rrr, rr)
+		rr.Values[i] = val
 	}
 
-	return rrr, nil
+	return rr, nil
 }
 
 // Execute is function to execute custon query, same like GetRecords
 func (m *Model) Execute(q string, values ...interface{}) ([]ResultRow, error) {
+	return m.ExecuteContext(context.Background(), q, values...)
+}
+
+// ExecuteContext is Execute with a context
+func (m *Model) ExecuteContext(ctx context.Context, q string, values ...interface{}) ([]ResultRow, error) {
 	InfoMessage("WARNING: Model.Execute() is deprecated. Use QueryBuilder instead.")
 
 	if m == nil {
 		return []ResultRow{}, errors.New("cannot perform action: Execute() on nil model")
 	}
 
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	q = rebind(m.dialect(), q)
 	m.lastQuery = q
 	m.lastValues = values
-	r, err := m.DB.Query(q, values...)
+	r, err := m.conn().QueryContext(ctx, q, values...)
 
 	if err != nil {
 		return nil, err
 	}
+	defer r.Close()
 
 	typ, err := r.ColumnTypes()
 	if err != nil {
@@ -408,11 +446,12 @@ func (m *Model) Execute(q string, values ...interface{}) ([]ResultRow, error) {
 
 	for r.Next() {
 		var rr ResultRow
-		rr.Values = make([]interface{}, len(m.Fields))
-		rr.pointers = make([]interface{}, len(m.Fields))
+		rr.Values = make([]interface{}, len(typ))
+		rr.pointers = make([]interface{}, len(typ))
 		rr.Fields = fld
+		rr.TableName = m.TableName
 
-		for i := range m.Fields {
+		for i := range typ {
 			rr.pointers[i] = &rr.Values[i]
 		}
 
@@ -433,292 +472,535 @@ func (m *Model) Execute(q string, values ...interface{}) ([]ResultRow, error) {
 }
 
 // Deprecated: Execute save query
-func (m *Model) Save(fields []SQLField) (bool, error) {
+func (m *Model) Save(fields []SQLField) (WriteResult, error) {
 	return m.Insert(fields)
 }
 
-// Execute INSERT query
-func (m *Model) Insert(fields []SQLField) (bool, error) {
+// Execute INSERT query, the result holds the generated primary key (LastInsertId)
+func (m *Model) Insert(fields []SQLField) (WriteResult, error) {
+	return m.InsertContext(context.Background(), fields)
+}
+
+// InsertContext is Insert with a context
+func (m *Model) InsertContext(ctx context.Context, fields []SQLField) (WriteResult, error) {
 	if m == nil {
-		return false, errors.New("cannot perform action: Insert() on nil model")
+		return WriteResult{}, errors.New("cannot perform action: Insert() on nil model")
 	}
 
-	q, values := BuildQuery(QueryTypeInsert, fields,
-		SQLTable{TableName: m.TableName, PKField: m.PKField}, []SQLJoin{}, []Filter{}, "", "", 0)
+	return m.withHooks(ctx, &HookContext{Event: BeforeInsert, Fields: fields}, func(tm *Model, fields []SQLField) (WriteResult, error) {
+		return tm.insert(ctx, fields)
+	})
+}
+
+// insert executes the INSERT statement, hooks are not run
+func (m *Model) insert(ctx context.Context, fields []SQLField) (WriteResult, error) {
+	fields = m.withInsertVersion(m.withTimestamps(fields, true))
+	if err := m.checkFields(fields); err != nil {
+		return WriteResult{}, err
+	}
 
-	success, err := executeWithContext(m, q, values)
+	d := m.dialect()
+	q, values, err := buildQuery(d, QueryTypeInsert, fields,
+		SQLTable{TableName: m.TableName, PKField: m.PKField}, []SQLJoin{}, []Filter{}, "", "", 0, 0)
+	if err != nil {
+		return WriteResult{}, err
+	}
+
+	// Dialects without LastInsertId support (PostgreSQL) return the key with RETURNING
+	if ret := d.Returning(m.PKField); len(m.PKField) > 0 && len(ret) > 0 {
+		res, err := queryReturningWithContext(ctx, m, q+ret, values)
+		if err != nil {
+			InfoMessage(q)
+			return WriteResult{}, err
+		}
+		return res, nil
+	}
+
+	res, err := executeWithContext(ctx, m, q, values)
 	if err != nil {
 		InfoMessage(q)
-		return false, err
+		return WriteResult{}, err
 	}
 
-	if success {
-		return true, nil
+	return res, nil
+}
+
+// maxInsertRows is the max number of rows of a multi-row INSERT statement
+const maxInsertRows = 1000
+
+// maxParams returns the max number of bind parameters of a statement
+func maxParams(d Dialect) int {
+	switch d.(type) {
+	case MySQLDialect, PostgresDialect:
+		return 65535
 	}
+	return 999 // SQLite before 3.32
+}
 
-	InfoMessage(q)
-	return false, errors.New("unknown eror occured, check your sql sytax statement")
+// InsertMany inserts rows with chunked multi-row INSERT statements in one transaction
+// (a savepoint when the model is bound to a transaction). Every row must have the same fields,
+// the result holds the total rows affected, LastInsertId is not set.
+func (m *Model) InsertMany(rows [][]SQLField) (WriteResult, error) {
+	return m.InsertManyContext(context.Background(), rows)
 }
 
-// Execute UPDATE query
-func (m *Model) Update(fields []SQLField, id string) (bool, error) {
+// InsertManyContext is InsertMany with a context
+func (m *Model) InsertManyContext(ctx context.Context, rows [][]SQLField) (WriteResult, error) {
 	if m == nil {
-		return false, errors.New("cannot perform action: Update() on nil model")
+		return WriteResult{}, errors.New("cannot perform action: InsertMany() on nil model")
+	}
+	if len(rows) == 0 {
+		return WriteResult{}, nil
 	}
 
-	q, values := BuildQuery(QueryTypeUpdate, fields,
-		SQLTable{TableName: m.TableName, PKField: m.PKField}, []SQLJoin{}, []Filter{{Field: m.PKField, Operator: "=", Value: id}}, "", "", 0)
+	if m.hasHooks(BeforeInsert, AfterInsert) {
+		var res WriteResult
+		err := m.inTx(ctx, func(tm *Model) error {
+			hooked := make([][]SQLField, len(rows))
+			for i, row := range rows {
+				hc := &HookContext{Model: tm, Event: BeforeInsert, Fields: row}
+				if err := tm.runHooks(ctx, hc); err != nil {
+					return err
+				}
+				hooked[i] = hc.Fields
+			}
 
-	success, err := executeWithContext(m, q, values)
-	if err != nil {
-		InfoMessage(q)
-		return false, err
+			var err error
+			if res, err = tm.insertMany(ctx, hooked); err != nil {
+				return err
+			}
+
+			// LastInsertId of the rows is unknown, the key is set when the row has the key values
+			for _, row := range hooked {
+				hc := &HookContext{Model: tm, Event: AfterInsert, Fields: row, Result: WriteResult{RowsAffected: 1}}
+				if key := tm.fieldsKey(row); key != nil {
+					hc.ID, hc.Key = tm.keyText(key), key
+				}
+				if err := tm.runHooks(ctx, hc); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return WriteResult{}, err
+		}
+		return res, nil
 	}
 
-	if success {
-		return true, nil
+	return m.insertMany(ctx, rows)
+}
+
+// insertMany executes the multi-row INSERT statements, hooks are not run
+func (m *Model) insertMany(ctx context.Context, rows [][]SQLField) (WriteResult, error) {
+	if len(m.CreatedAtField) > 0 || len(m.UpdatedAtField) > 0 || len(m.VersionField) > 0 {
+		stamped := make([][]SQLField, len(rows))
+		for i, row := range rows {
+			stamped[i] = m.withInsertVersion(m.withTimestamps(row, true))
+		}
+		rows = stamped
 	}
 
-	InfoMessage(q)
-	return false, errors.New("unknown eror occured, check your sql sytax statement")
+	// All rows in the field order of the first row
+	names := make([]string, len(rows[0]))
+	for i, f := range rows[0] {
+		names[i] = f.FieldName
+	}
+	if len(names) == 0 {
+		return WriteResult{}, errors.New("no fields to insert")
+	}
+	if err := m.checkFields(rows[0]); err != nil {
+		return WriteResult{}, err
+	}
+
+	values := make([][]interface{}, len(rows))
+	for r, row := range rows {
+		if len(row) != len(names) {
+			return WriteResult{}, fmt.Errorf("row %d has %d fields, expected %d", r, len(row), len(names))
+		}
+		values[r] = make([]interface{}, len(names))
+		for _, f := range row {
+			i := FindInSlice(names, f.FieldName)
+			if i < 0 {
+				return WriteResult{}, fmt.Errorf("row %d: field %q is not in the first row", r, f.FieldName)
+			}
+			values[r][i] = f.Value
+		}
+	}
+
+	d := m.dialect()
+	chunk := maxParams(d) / len(names)
+	if chunk > maxInsertRows {
+		chunk = maxInsertRows
+	}
+	if chunk < 1 {
+		return WriteResult{}, errors.New("too many fields to insert")
+	}
+
+	var res WriteResult
+	err := m.inTx(ctx, func(tm *Model) error {
+		for start := 0; start < len(values); start += chunk {
+			end := start + chunk
+			if end > len(values) {
+				end = len(values)
+			}
+
+			q, args, err := buildStatement(d, QueryTypeInsert, rows[0],
+				SQLTable{TableName: m.TableName, PKField: m.PKField}, []SQLJoin{}, []Filter{}, "", nil, "", 0, 0)
+			if err != nil {
+				return err
+			}
+
+			placeholders := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ") + ")"
+			args = args[:0]
+			for i, v := range values[start:end] {
+				if i > 0 {
+					q = q + ", " + placeholders
+				}
+				args = append(args, v...)
+			}
+
+			r, err := executeWithContext(ctx, tm, rebind(d, q), args)
+			if err != nil {
+				InfoMessage(q)
+				return err
+			}
+			res.RowsAffected += r.RowsAffected
+		}
+		return nil
+	})
+	if err != nil {
+		return WriteResult{}, err
+	}
+
+	return res, nil
+}
+
+// Upsert inserts a row or updates updateFields of the existing row with the same conflictKeys,
+// ON DUPLICATE KEY UPDATE on MySql (conflictKeys are ignored, the table keys are used), ON CONFLICT on PostgreSQL and SQLite.
+// Without updateFields existing rows are left unchanged. Like Update the UpdatedAtField is set and the VersionField
+// is incremented, both are ignored in updateFields, CreatedAtField is updated only when fields set it. MySql reports 2 rows affected when an existing row is updated,
+// LastInsertId is reliable only when a new row was inserted.
+func (m *Model) Upsert(fields []SQLField, conflictKeys []string, updateFields []string) (WriteResult, error) {
+	return m.UpsertContext(context.Background(), fields, conflictKeys, updateFields)
 }
 
-// Execute DELETE query
-func (m *Model) Delete(id string) (bool, error) {
+// UpsertContext is Upsert with a context
+func (m *Model) UpsertContext(ctx context.Context, fields []SQLField, conflictKeys []string, updateFields []string) (WriteResult, error) {
 	if m == nil {
-		return false, errors.New("cannot perform action: Delete() on nil model")
+		return WriteResult{}, errors.New("cannot perform action: Upsert() on nil model")
+	}
+
+	return m.withHooks(ctx, &HookContext{Event: BeforeInsert, Fields: fields}, func(tm *Model, fields []SQLField) (WriteResult, error) {
+		return tm.upsert(ctx, fields, conflictKeys, updateFields)
+	})
+}
+
+// upsertSet returns the columns the conflict clause sets to the inserted values, the timestamps are set
+// like Update (see withTimestamps): CreatedAtField only when fields set it, UpdatedAtField is always added.
+// The VersionField is left out, increment reports that the clause must increment it.
+func (m *Model) upsertSet(fields []SQLField, updateFields []string) (set []string, increment bool) {
+	stamped := m.withTimestamps(fields, false)
+	set = make([]string, 0, len(updateFields)+1)
+	for _, f := range updateFields {
+		if len(f) == 0 || f == m.VersionField || FindInSlice(set, f) >= 0 {
+			continue
+		}
+		if f == m.CreatedAtField && fieldIndex(stamped, f) < 0 {
+			continue
+		}
+		set = append(set, f)
+	}
+	if len(set) == 0 {
+		return set, false
+	}
+
+	if len(m.UpdatedAtField) > 0 && FindInSlice(set, m.UpdatedAtField) < 0 {
+		set = append(set, m.UpdatedAtField)
+	}
+	return set, len(m.VersionField) > 0
+}
+
+// fieldIndex returns the index of the field named name, -1 when fields has no such field
+func fieldIndex(fields []SQLField, name string) int {
+	for i, f := range fields {
+		if f.FieldName == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// upsert executes the INSERT ... ON CONFLICT statement, hooks are not run
+func (m *Model) upsert(ctx context.Context, fields []SQLField, conflictKeys []string, updateFields []string) (WriteResult, error) {
+	d := m.dialect()
+	if _, ok := d.(MySQLDialect); !ok && len(conflictKeys) == 0 {
+		return WriteResult{}, errors.New("upsert needs the conflict key columns")
+	}
+
+	updateFields, increment := m.upsertSet(fields, updateFields)
+	fields = m.withInsertVersion(m.withTimestamps(fields, true))
+
+	if err := m.checkFields(fields); err != nil {
+		return WriteResult{}, err
+	}
+	for _, k := range append(append([]string{}, conflictKeys...), updateFields...) {
+		if !validIdent(k, false) {
+			return WriteResult{}, fmt.Errorf("%w: %q", ErrInvalidIdentifier, k)
+		}
+		if err := m.checkColumn(k); err != nil {
+			return WriteResult{}, err
+		}
 	}
 
-	q, values := BuildQuery(QueryTypeDelete, []SQLField{},
-		SQLTable{TableName: m.TableName, PKField: m.PKField}, []SQLJoin{}, []Filter{{Field: m.PKField, Operator: "=", Value: id}}, "", "", 0)
+	q, values, err := buildStatement(d, QueryTypeInsert, fields,
+		SQLTable{TableName: m.TableName, PKField: m.PKField}, []SQLJoin{}, []Filter{}, "", nil, "", 0, 0)
+	if err != nil {
+		return WriteResult{}, err
+	}
+	q = q + d.Upsert(conflictKeys, updateFields)
+	if increment {
+		// Optimistic locking, the version of the updated row is incremented like Update
+		col := d.QuoteIdent(m.VersionField)
+		q = q + ", " + col + " = " + d.QuoteIdent(m.TableName) + "." + col + " + 1"
+	}
+	q = rebind(d, q)
 
-	success, err := executeWithContext(m, q, values)
+	if ret := d.Returning(m.PKField); len(m.PKField) > 0 && len(ret) > 0 {
+		res, err := queryReturningWithContext(ctx, m, q+ret, values)
+		if err != nil {
+			InfoMessage(q)
+			return WriteResult{}, err
+		}
+		return res, nil
+	}
+
+	res, err := executeWithContext(ctx, m, q, values)
 	if err != nil {
 		InfoMessage(q)
-		return false, err
+		return WriteResult{}, err
+	}
+
+	return res, nil
+}
+
+// Execute UPDATE query, with RequireRowsAffected ErrRecordNotFound is returned when no row matched the id.
+// With VersionField a StaleRecordError is returned when the version in fields is not the current one
+func (m *Model) Update(fields []SQLField, id string) (WriteResult, error) {
+	return m.UpdateContext(context.Background(), fields, id)
+}
+
+// UpdateContext is Update with a context
+func (m *Model) UpdateContext(ctx context.Context, fields []SQLField, id string) (WriteResult, error) {
+	if m == nil {
+		return WriteResult{}, errors.New("cannot perform action: Update() on nil model")
 	}
 
-	if success {
-		return true, nil
+	key, err := m.idKey(id)
+	if err != nil {
+		return WriteResult{}, err
 	}
 
-	InfoMessage(q)
-	return false, errors.New("unknown eror occured, check your sql sytax statement")
+	return m.withHooks(ctx, &HookContext{Event: BeforeUpdate, Fields: fields, ID: id, Key: key}, func(tm *Model, fields []SQLField) (WriteResult, error) {
+		return tm.update(ctx, fields, key)
+	})
 }
 
-func executeWithContext(m *Model, q string, values []interface{}) (bool, error) {
-	// Create context
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
+// update executes the UPDATE statement of a record, hooks are not run
+func (m *Model) update(ctx context.Context, fields []SQLField, key Key) (WriteResult, error) {
+	fields = m.withTimestamps(fields, false)
+	if err := m.checkFields(fields); err != nil {
+		return WriteResult{}, err
+	}
 
-	// Prepare
-	stmt, err := m.DB.Prepare(q)
+	filters, err := m.keyFilters(key, false)
 	if err != nil {
-		return false, err
+		return WriteResult{}, err
 	}
 
-	defer stmt.Close()
+	// Optimistic locking, see VersionField
+	fields, version, checkVersion := m.withVersion(fields)
+	if checkVersion {
+		filters = append(filters, Filter{Field: m.VersionField, Operator: "=", Value: version, Logic: "AND"})
+	}
 
-	// Execute
-	_, err = stmt.ExecContext(ctx, values...)
+	q, values, err := buildQuery(m.dialect(), QueryTypeUpdate, fields,
+		SQLTable{TableName: m.TableName, PKField: m.PKField}, []SQLJoin{}, filters, "", "", 0, 0)
+	if err != nil {
+		return WriteResult{}, err
+	}
 
+	res, err := executeWithContext(ctx, m, q, values)
 	if err != nil {
 		InfoMessage(q)
-		return false, err
+		return WriteResult{}, err
 	}
 
-	return true, nil
-}
+	// LastInsertId is meaningful only for INSERT
+	res.LastInsertId = 0
 
-// Construct Filed function
-func constructField(ct *sql.ColumnType, val interface{}) (interface{}, error) {
-	if val == nil {
-		return nil, nil
+	if checkVersion && res.RowsAffected == 0 {
+		return res, m.staleRecord(ctx, key, version)
 	}
 
-	var b []byte
-	var n string
+	if m.RequireRowsAffected && res.RowsAffected == 0 {
+		return res, ErrRecordNotFound
+	}
 
-	switch v := val.(type) {
-	case int:
-		n = strconv.FormatInt(val.(int64), 10)
-	case int64:
-		n = strconv.FormatInt(val.(int64), 10)
-	case float64:
-		n = strconv.FormatFloat(val.(float64), 'f', 64, 64)
-		b = []byte(n)
-	case float32:
-		n = strconv.FormatFloat(float64(val.(float32)), 'f', 32, 32)
-		b = []byte(n)
-	case []uint8:
-		b = val.([]byte)
-		n = string(b)
-	default:
-		_ = v
-		//b = val.([]byte)
+	return res, nil
+}
+
+// Execute DELETE query, with RequireRowsAffected ErrRecordNotFound is returned when no row matched the id.
+// Models with SoftDeleteField are soft deleted, see ForceDelete
+func (m *Model) Delete(id string) (WriteResult, error) {
+	return m.DeleteContext(context.Background(), id)
+}
+
+// DeleteContext is Delete with a context
+func (m *Model) DeleteContext(ctx context.Context, id string) (WriteResult, error) {
+	if m == nil {
+		return WriteResult{}, errors.New("cannot perform action: Delete() on nil model")
 	}
 
-	switch ct.DatabaseTypeName() {
-	case "BIT":
-		return b[0], nil
-	case "INT", "TINYINT", "SMALLINT", "MEDIUMINT":
-		val, err := strconv.ParseInt(n, 10, 32)
-		if err != nil {
-			//fmt.Println(err)
-			return nil, err
-		}
-		return val, nil
-	case "BIGINT":
-		val, err := strconv.ParseInt(n, 10, 64)
-		if err != nil {
-			//fmt.Println(err)
-			return nil, err
-		}
-		return val, nil
+	key, err := m.idKey(id)
+	if err != nil {
+		return WriteResult{}, err
+	}
 
-	case "FLOAT", "DECIMAL":
-		n := string(b)
-		val, err := strconv.ParseFloat(n, 32)
-		if err != nil {
-			//fmt.Println(err)
-			return nil, err
-		}
-		return val, nil
-	case "DOUBLE":
-		n := string(b)
-		intVar, err := strconv.ParseFloat(n, 64)
-		if err != nil {
-			//fmt.Println(err)
-			return nil, err
-		}
-		return intVar, nil
-	case "CHAR", "VARCHAR", "TINYTEXT", "MEDIUMTEXT", "LONGTEXT", "TEXT", "JSON", "BLOB", "TINYBLOB", "MEDIUMBLOB", "LONGBLOB":
-		return string(b), nil
-	case "DATE":
-		n := string(b)
-		t, err := time.Parse("2006-01-02", n)
-		if err != nil {
-			//fmt.Println(err)
-			return nil, err
-		}
-		return t, nil
-	case "DATETIME", "TIMESTAMP":
-		n := string(b)
-		t, err := time.Parse("2006-01-02 15:04:05", n)
-		if err != nil {
-			//fmt.Println(err)
-			return nil, err
-		}
-		return t, nil
-	case "TIME":
-		n := string(b)
-		t, err := time.Parse("15:04:05", n)
-		if err != nil {
-			//fmt.Println(err)
-			return nil, err
-		}
-		return t, nil
-	case "YEAR":
-		n := string(b)
-		t, err := time.Parse("2006", n)
-		if err != nil {
-			//fmt.Println(err)
-			return nil, err
+	return m.withHooks(ctx, &HookContext{Event: BeforeDelete, ID: id, Key: key}, func(tm *Model, fields []SQLField) (WriteResult, error) {
+		return tm.delete(ctx, key)
+	})
+}
+
+// delete executes the DELETE statement of a record (soft delete with SoftDeleteField), hooks are not run
+func (m *Model) delete(ctx context.Context, key Key) (WriteResult, error) {
+	// Soft delete, see SoftDeleteField
+	if len(m.SoftDeleteField) > 0 {
+		res, err := m.NewQueryBuilder().whereKey(key).delete(ctx, false)
+		if err == nil && m.RequireRowsAffected && res.RowsAffected == 0 {
+			return res, ErrRecordNotFound
 		}
-		return t, nil
+		return res, err
 	}
-	return nil, nil
-}
 
-// Build query func
-func BuildQuery(queryType QueryType, fields []SQLField, table SQLTable, joins []SQLJoin, wheres []Filter, group string, order string, limit int64) (string, []interface{}) {
-	q := ""
-	s := ""
-	j := ""
-	w := ""
-	g := ""
-	o := ""
-	l := ""
+	filters, err := m.keyFilters(key, false)
+	if err != nil {
+		return WriteResult{}, err
+	}
 
-	//SELECT
-	if len(fields) > 0 {
-		for _, fld := range fields {
-			s = s + fld.FieldName + ", "
-		}
-		s = s[:len(s)-2]
-	} else {
-		s = "*"
+	q, values, err := buildQuery(m.dialect(), QueryTypeDelete, []SQLField{},
+		SQLTable{TableName: m.TableName, PKField: m.PKField}, []SQLJoin{}, filters, "", "", 0, 0)
+	if err != nil {
+		return WriteResult{}, err
 	}
 
-	//JOIN
-	for _, jn := range joins {
-		j = j + " " + string(jn.Join_type) + " JOIN " + jn.Foreign_table + " ON "
-		j = j + jn.Foreign_table + "." + jn.KeyPair.ForeignKey + "=" + table.TableName + "." + jn.KeyPair.LocalKey
+	res, err := executeWithContext(ctx, m, q, values)
+	if err != nil {
+		InfoMessage(q)
+		return WriteResult{}, err
 	}
 
-	//WHERE
-	var values = make([]interface{}, 0)
-	if len(wheres) > 0 {
-		w = " WHERE "
-		for _, f := range wheres {
-			if len(f.Logic) > 0 {
-				w = w + " " + f.Logic + " "
-			}
-			w = w + "(" + f.Field + " " + f.Operator + " ?)"
-			values = append(values, f.Value)
-		}
+	// LastInsertId is meaningful only for INSERT
+	res.LastInsertId = 0
+
+	if m.RequireRowsAffected && res.RowsAffected == 0 {
+		return res, ErrRecordNotFound
 	}
 
-	//GROUP BY
-	if len(group) > 0 {
-		g = " " + group
+	return res, nil
+}
+
+// executeWithContext prepares and executes a statement, the query timeout is applied to ctx
+func executeWithContext(ctx context.Context, m *Model, q string, values []interface{}) (WriteResult, error) {
+	// Create context
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	// Prepare
+	stmt, err := m.conn().PrepareContext(ctx, q)
+	if err != nil {
+		return WriteResult{}, err
 	}
 
-	//ORDER
-	if len(order) > 0 {
-		o = " " + order
+	defer stmt.Close()
+
+	// Execute
+	r, err := stmt.ExecContext(ctx, values...)
+
+	if err != nil {
+		InfoMessage(q)
+		return WriteResult{}, err
 	}
 
-	//LIMIT
-	if limit > 0 {
-		l = " LIMIT " + strconv.FormatInt(int64(limit), 10)
+	return newWriteResult(r), nil
+}
+
+// queryReturningWithContext executes an INSERT ... RETURNING statement and reads the returned key
+func queryReturningWithContext(ctx context.Context, m *Model, q string, values []interface{}) (WriteResult, error) {
+	ctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	r, err := m.conn().QueryContext(ctx, q, values...)
+	if err != nil {
+		return WriteResult{}, err
 	}
+	defer r.Close()
 
-	switch queryType {
-	case QueryTypeSelect:
-		q = "SELECT " + s + " FROM " + table.TableName + j + w + g + o + l
-	case QueryTypeInsert:
-		q = "INSERT INTO " + table.TableName + " (" + s + ") VALUES ("
-		for _, fld := range fields {
-			q = q + "?, "
-			values = append(values, fld.Value)
+	var res WriteResult
+	for r.Next() {
+		var id interface{}
+		if err := r.Scan(&id); err != nil {
+			return WriteResult{}, err
 		}
-		q = q[:len(q)-2] + ")"
-
-	case QueryTypeUpdate:
-		q = "UPDATE " + table.TableName + " SET "
-		for _, fld := range fields {
-			q = q + fld.FieldName + " = ?, "
-			values = append(values, fld.Value)
+		if n, ok := id.(int64); ok {
+			res.LastInsertId = n
 		}
-		v0 := values[0]
-		values = values[1:]
-		values = append(values, v0)
-		q = q[:len(q)-2] + w
+		res.RowsAffected++
+	}
 
-	case QueryTypeDelete:
-		q = "DELETE FROM " + table.TableName + w
-	default:
-		q = ""
+	return res, r.Err()
+}
+
+// newWriteResult reads the sql.Result values, unsupported values are left to zero
+func newWriteResult(r sql.Result) WriteResult {
+	var res WriteResult
+	if id, err := r.LastInsertId(); err == nil {
+		res.LastInsertId = id
+	}
+	if n, err := r.RowsAffected(); err == nil {
+		res.RowsAffected = n
 	}
+	return res
+}
 
-	return q, values
+// BuildQuery builds a query for the default dialect, see SetDefaultDialect.
+// Identifiers are validated and quoted, an error is returned for invalid identifiers and operators.
+func BuildQuery(queryType QueryType, fields []SQLField, table SQLTable, joins []SQLJoin, wheres []Filter, group string, order string, limit int64) (string, []interface{}, error) {
+	return buildQuery(GetDialect(nil), queryType, fields, table, joins, wheres, group, order, limit, 0)
 }
 
 // BuildQueryExtended - improved version with OFFSET and IN clause support
 func BuildQueryExtended(queryType QueryType, fields []SQLField, table SQLTable,
 	joins []SQLJoin, wheres []Filter, group string, order string,
-	limit int64, offset int64) (string, []interface{}) {
+	limit int64, offset int64) (string, []interface{}, error) {
+	return buildQuery(GetDialect(nil), queryType, fields, table, joins, wheres, group, order, limit, offset)
+}
+
+// buildQuery builds the query for a dialect, values are returned in placeholder order
+func buildQuery(d Dialect, queryType QueryType, fields []SQLField, table SQLTable,
+	joins []SQLJoin, wheres []Filter, group string, order string,
+	limit int64, offset int64) (string, []interface{}, error) {
+	q, values, err := buildStatement(d, queryType, fields, table, joins, wheres, group, nil, order, limit, offset)
+	if err != nil {
+		return "", nil, err
+	}
+	return rebind(d, q), values, nil
+}
+
+// buildStatement builds the query with ? placeholders (not rebound to the dialect style, used for subqueries)
+func buildStatement(d Dialect, queryType QueryType, fields []SQLField, table SQLTable,
+	joins []SQLJoin, wheres []Filter, group string, having []Filter, order string,
+	limit int64, offset int64) (string, []interface{}, error) {
 
 	q := ""
 	s := ""
@@ -728,11 +1010,19 @@ func BuildQueryExtended(queryType QueryType, fields []SQLField, table SQLTable,
 	o := ""
 	l := ""
 
+	tbl, err := quoteTable(d, table.TableName)
+	if err != nil {
+		return "", nil, err
+	}
+
 	// SELECT
-	if len(fields) > 0 {
+	if len(fields) > 0 && queryType == QueryTypeSelect {
 		fieldNames := make([]string, len(fields))
 		for i, fld := range fields {
-			fieldNames[i] = fld.FieldName
+			fieldNames[i], _, err = quoteSelectExpr(d, fld.FieldName)
+			if err != nil {
+				return "", nil, err
+			}
 		}
 		s = strings.Join(fieldNames, ", ")
 	} else {
@@ -741,85 +1031,245 @@ func BuildQueryExtended(queryType QueryType, fields []SQLField, table SQLTable,
 
 	// JOIN
 	for _, jn := range joins {
-		j = j + " " + string(jn.Join_type) + " JOIN " + jn.Foreign_table + " ON "
-		j = j + jn.Foreign_table + "." + jn.KeyPair.ForeignKey + "=" +
-			table.TableName + "." + jn.KeyPair.LocalKey
-	}
-
-	// WHERE with IN clause support
-	var values = make([]interface{}, 0)
-	if len(wheres) > 0 {
-		w = " WHERE "
-		for i, f := range wheres {
-			if i > 0 && len(f.Logic) > 0 {
-				w = w + " " + f.Logic + " "
-			}
+		jt := strings.ToUpper(strings.TrimSpace(string(jn.Join_type)))
+		if jt != "" && jt != string(ModelJoinInner) && jt != string(ModelJoinLeft) && jt != string(ModelJoinRight) {
+			return "", nil, fmt.Errorf("%w: join type %q", ErrInvalidOperator, jn.Join_type)
+		}
 
-			// Handle IN clause
-			if f.Operator == "IN" {
-				inValues, ok := f.Value.([]interface{})
-				if !ok {
-					// Try to convert single value to slice
-					inValues = []interface{}{f.Value}
-				}
+		ft, err := quoteTable(d, jn.Foreign_table)
+		if err != nil {
+			return "", nil, err
+		}
+		fk, err := quoteColumn(d, jn.KeyPair.ForeignKey)
+		if err != nil {
+			return "", nil, err
+		}
+		lk, err := quoteColumn(d, jn.KeyPair.LocalKey)
+		if err != nil {
+			return "", nil, err
+		}
 
-				placeholders := make([]string, len(inValues))
-				for j := range inValues {
-					placeholders[j] = "?"
-					values = append(values, inValues[j])
-				}
-				w = w + "(" + f.Field + " IN (" + strings.Join(placeholders, ", ") + "))"
-			} else {
-				w = w + "(" + f.Field + " " + f.Operator + " ?)"
-				values = append(values, f.Value)
+		if len(jn.Pivot.Table) > 0 {
+			pt, err := quoteTable(d, jn.Pivot.Table)
+			if err != nil {
+				return "", nil, err
+			}
+			plk, err := quoteColumn(d, jn.Pivot.LocalKey)
+			if err != nil {
+				return "", nil, err
 			}
+			pfk, err := quoteColumn(d, jn.Pivot.ForeignKey)
+			if err != nil {
+				return "", nil, err
+			}
+			j = j + " " + jt + " JOIN " + pt + " ON " + pt + "." + plk + "=" + tbl + "." + lk
+			j = j + " " + jt + " JOIN " + ft + " ON " + ft + "." + fk + "=" + pt + "." + pfk
+			continue
 		}
+		j = j + " " + jt + " JOIN " + ft + " ON " + ft + "." + fk + "=" + tbl + "." + lk
+	}
+
+	// WHERE
+	whereValues := make([]interface{}, 0)
+	if len(wheres) > 0 {
+		var cond string
+		cond, whereValues, err = buildConditions(d, wheres)
+		if err != nil {
+			return "", nil, err
+		}
+		w = " WHERE " + cond
 	}
 
 	// GROUP BY
-	if len(group) > 0 {
-		g = " " + group
+	groupCols, err := parseGroupBy(group)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(groupCols) > 0 {
+		for i, c := range groupCols {
+			groupCols[i] = d.QuoteIdent(c)
+		}
+		g = " GROUP BY " + strings.Join(groupCols, ", ")
 	}
 
-	// ORDER
-	if len(order) > 0 {
-		o = " " + order
+	// HAVING
+	havingValues := make([]interface{}, 0)
+	if len(having) > 0 {
+		var cond string
+		cond, havingValues, err = buildHavingConditions(d, having)
+		if err != nil {
+			return "", nil, err
+		}
+		g = g + " HAVING " + cond
 	}
 
-	// LIMIT and OFFSET
-	if limit > 0 {
-		l = " LIMIT " + strconv.FormatInt(limit, 10)
-		if offset > 0 {
-			l = l + " OFFSET " + strconv.FormatInt(offset, 10)
+	// ORDER
+	terms, err := parseOrderBy(order)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(terms) > 0 {
+		ot := make([]string, len(terms))
+		for i, t := range terms {
+			ot[i] = d.QuoteIdent(t.column) + " " + t.direction
 		}
+		o = " ORDER BY " + strings.Join(ot, ", ")
 	}
 
+	// LIMIT and OFFSET
+	l = d.LimitOffset(limit, offset)
+
+	var values = make([]interface{}, 0)
 	switch queryType {
 	case QueryTypeSelect:
-		q = "SELECT " + s + " FROM " + table.TableName + j + w + g + o + l
+		q = "SELECT " + s + " FROM " + tbl + j + w + g + o + l
+		values = append(values, whereValues...)
+		values = append(values, havingValues...)
 	case QueryTypeInsert:
 		fieldNames := make([]string, len(fields))
 		placeholders := make([]string, len(fields))
 		for i, fld := range fields {
-			fieldNames[i] = fld.FieldName
+			if fieldNames[i], err = quoteColumn(d, fld.FieldName); err != nil {
+				return "", nil, err
+			}
 			placeholders[i] = "?"
 			values = append(values, fld.Value)
 		}
-		q = "INSERT INTO " + table.TableName +
+		q = "INSERT INTO " + tbl +
 			" (" + strings.Join(fieldNames, ", ") + ") VALUES (" +
 			strings.Join(placeholders, ", ") + ")"
 	case QueryTypeUpdate:
 		setParts := make([]string, len(fields))
 		for i, fld := range fields {
-			setParts[i] = fld.FieldName + " = ?"
+			col, err := quoteColumn(d, fld.FieldName)
+			if err != nil {
+				return "", nil, err
+			}
+			if inc, ok := fld.Value.(sqlIncrement); ok {
+				setParts[i] = col + " = " + col + " + ?"
+				values = append(values, int64(inc))
+				continue
+			}
+			setParts[i] = col + " = ?"
 			values = append(values, fld.Value)
 		}
-		q = "UPDATE " + table.TableName + " SET " + strings.Join(setParts, ", ") + w
+		q = "UPDATE " + tbl + " SET " + strings.Join(setParts, ", ") + w
+		values = append(values, whereValues...)
 	case QueryTypeDelete:
-		q = "DELETE FROM " + table.TableName + w
+		q = "DELETE FROM " + tbl + w
+		values = append(values, whereValues...)
 	default:
-		q = ""
+		return "", nil, errors.New("unknown query type: " + string(queryType))
+	}
+
+	return q, values, nil
+}
+
+// buildConditions builds the conditions of a WHERE clause joined with the filter logic (AND / OR)
+func buildConditions(d Dialect, wheres []Filter) (string, []interface{}, error) {
+	return joinConditions(d, wheres, false)
+}
+
+// buildHavingConditions builds the conditions of a HAVING clause, fields can be aggregates (COUNT(*), SUM(price) ...)
+func buildHavingConditions(d Dialect, having []Filter) (string, []interface{}, error) {
+	return joinConditions(d, having, true)
+}
+
+// joinConditions builds the conditions joined with the filter logic (AND / OR)
+func joinConditions(d Dialect, wheres []Filter, aggregates bool) (string, []interface{}, error) {
+	c := ""
+	values := make([]interface{}, 0)
+	for i, f := range wheres {
+		if i > 0 {
+			logic, err := normalizeLogic(f.Logic)
+			if err != nil {
+				return "", nil, err
+			}
+			c = c + " " + logic + " "
+		}
+
+		cond, v, err := buildCondition(d, f, aggregates)
+		if err != nil {
+			return "", nil, err
+		}
+		c = c + cond
+		values = append(values, v...)
+	}
+	return c, values, nil
+}
+
+// buildCondition builds a single filter condition
+func buildCondition(d Dialect, f Filter, aggregates bool) (string, []interface{}, error) {
+	// Nested group
+	if len(f.Group) > 0 {
+		cond, values, err := joinConditions(d, f.Group, aggregates)
+		if err != nil {
+			return "", nil, err
+		}
+		return "(" + cond + ")", values, nil
+	}
+
+	// Raw expression
+	if len(f.Raw) > 0 {
+		args := listValues(f.Value)
+		if n := countPlaceholders(f.Raw); n != len(args) {
+			return "", nil, fmt.Errorf("raw condition %q has %d placeholders and %d values", f.Raw, n, len(args))
+		}
+		return "(" + f.Raw + ")", args, nil
+	}
+
+	op, err := normalizeOperator(f.Operator)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Subquery
+	if op == "EXISTS" || op == "NOT EXISTS" {
+		sub, ok := f.Value.(*QueryBuilder)
+		if !ok || sub == nil {
+			return "", nil, errors.New(op + " needs a *QueryBuilder value")
+		}
+		sq, values, err := sub.buildStatement()
+		if err != nil {
+			return "", nil, err
+		}
+		return "(" + op + " (" + sq + "))", values, nil
+	}
+
+	var col string
+	if aggregates {
+		col, _, err = quoteSelectExpr(d, f.Field)
+	} else {
+		col, err = quoteColumn(d, f.Field)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch op {
+	case "IN", "NOT IN":
+		inValues := listValues(f.Value)
+		if len(inValues) == 0 {
+			// Empty list: IN matches nothing, NOT IN matches everything
+			if op == "IN" {
+				return "(1 = 0)", nil, nil
+			}
+			return "(1 = 1)", nil, nil
+		}
+		placeholders := make([]string, len(inValues))
+		for j := range inValues {
+			placeholders[j] = "?"
+		}
+		return "(" + col + " " + op + " (" + strings.Join(placeholders, ", ") + "))", inValues, nil
+	case "BETWEEN", "NOT BETWEEN":
+		between := listValues(f.Value)
+		if len(between) != 2 {
+			return "", nil, errors.New(op + " needs two values for column: " + f.Field)
+		}
+		return "(" + col + " " + op + " ? AND ?)", between, nil
+	case "IS NULL", "IS NOT NULL":
+		return "(" + col + " " + op + ")", nil, nil
 	}
 
-	return q, values
+	return "(" + col + " " + op + " ?)", []interface{}{f.Value}, nil
 }
@@ -0,0 +1,225 @@
+package gomvc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testModel returns the model of the cars table in a new SQLite database file
+func testModel(t *testing.T) *Model {
+	t.Helper()
+	InitHelpers(&AppConfig{})
+
+	db, err := ConnectDatabaseSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	_, err = db.Exec(`CREATE TABLE cars (id INTEGER PRIMARY KEY AUTOINCREMENT, name VARCHAR(50) NOT NULL,
+		price DECIMAL(10,2), version INTEGER NOT NULL DEFAULT 1)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := &Model{}
+	if err := m.InitModel(db, "cars", "id"); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// insertCars inserts cars by name and returns their ids
+func insertCars(t *testing.T, m *Model, names ...string) []int64 {
+	t.Helper()
+	ids := make([]int64, 0, len(names))
+	for _, n := range names {
+		res, err := m.Insert([]SQLField{{FieldName: "name", Value: n}, {FieldName: "price", Value: "10.50"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, res.LastInsertId)
+	}
+	return ids
+}
+
+// carNames returns the names of the cars ordered by id
+func carNames(t *testing.T, m *Model) []string {
+	t.Helper()
+	rr, err := m.NewQueryBuilder().OrderBy("id", "ASC").Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(rr))
+	for i, r := range rr {
+		names[i] = r.String("name")
+	}
+	return names
+}
+
+func TestModelCRUD(t *testing.T) {
+	m := testModel(t)
+
+	if want := []string{"id", "name", "price", "version"}; !reflect.DeepEqual(m.Fields, want) {
+		t.Fatalf("Fields = %v, want %v", m.Fields, want)
+	}
+
+	ids := insertCars(t, m, "ford", "bmw")
+	if ids[0] != 1 || ids[1] != 2 {
+		t.Fatalf("LastInsertId = %v, want [1 2]", ids)
+	}
+
+	res, err := m.Update([]SQLField{{FieldName: "name", Value: "audi"}}, "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.RowsAffected != 1 {
+		t.Errorf("Update RowsAffected = %d, want 1", res.RowsAffected)
+	}
+
+	rr, err := m.GetRecords([]Filter{{Field: "name", Operator: "=", Value: "audi"}}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rr) != 1 || rr[0].Int("id") != 2 {
+		t.Fatalf("GetRecords = %v, want the record 2", rr)
+	}
+	if v, _ := rr[0].Value("price"); v != Decimal("10.5") {
+		t.Errorf("price = %#v, want Decimal 10.5", v)
+	}
+
+	if _, err := m.Delete("1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := carNames(t, m); len(got) != 1 || got[0] != "audi" {
+		t.Errorf("cars after delete = %v, want [audi]", got)
+	}
+
+	if _, err := m.Insert([]SQLField{{FieldName: "name; DROP TABLE cars", Value: "x"}}); !errors.Is(err, ErrInvalidIdentifier) {
+		t.Errorf("insert of an unknown field error = %v, want %v", err, ErrInvalidIdentifier)
+	}
+}
+
+// stockModel returns the model of a stock table keyed by code, with version and timestamp columns
+func stockModel(t *testing.T, m *Model) *Model {
+	t.Helper()
+	_, err := m.DB.Exec(`CREATE TABLE stock (code VARCHAR(10) PRIMARY KEY, qty INTEGER NOT NULL,
+		version INTEGER NOT NULL DEFAULT 1, created_at DATETIME, updated_at DATETIME)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Model{CreatedAtField: "created_at", UpdatedAtField: "updated_at", VersionField: "version"}
+	if err := s.InitModel(m.DB, "stock", "code"); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestInsertManySQLite(t *testing.T) {
+	m := testModel(t)
+
+	// more rows than fit in one statement
+	rows := make([][]SQLField, 1500)
+	for i := range rows {
+		rows[i] = []SQLField{{FieldName: "name", Value: fmt.Sprintf("car %d", i)}, {FieldName: "price", Value: i}}
+	}
+	res, err := m.InsertMany(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.RowsAffected != 1500 {
+		t.Errorf("InsertMany RowsAffected = %d, want 1500", res.RowsAffected)
+	}
+
+	// the rows are inserted in one transaction
+	bad := [][]SQLField{{{FieldName: "name", Value: "ok"}}, {{FieldName: "price", Value: 1}}}
+	if _, err := m.InsertMany(bad); err == nil {
+		t.Error("InsertMany of rows with different fields succeeded")
+	}
+	bad = [][]SQLField{{{FieldName: "name", Value: "ok"}}, {{FieldName: "name", Value: nil}}}
+	if _, err := m.InsertMany(bad); err == nil {
+		t.Error("InsertMany of a NULL name succeeded")
+	}
+	if n, err := m.NewQueryBuilder().Count(); err != nil || n != 1500 {
+		t.Errorf("Count after failed InsertMany = %d, %v, want 1500", n, err)
+	}
+
+	// the after hooks get the key of the row
+	s := stockModel(t, m)
+	keys := make([]Key, 0)
+	s.AddHook(AfterInsert, func(ctx context.Context, hc *HookContext) error {
+		if hc.Result.RowsAffected != 1 {
+			t.Errorf("AfterInsert RowsAffected = %d, want 1", hc.Result.RowsAffected)
+		}
+		keys = append(keys, hc.Key)
+		return nil
+	})
+	_, err = s.InsertMany([][]SQLField{
+		{{FieldName: "code", Value: "A1"}, {FieldName: "qty", Value: 1}},
+		{{FieldName: "code", Value: "B2"}, {FieldName: "qty", Value: 2}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Key{{"code": "A1"}, {"code": "B2"}}; !reflect.DeepEqual(keys, want) {
+		t.Errorf("AfterInsert keys = %v, want %v", keys, want)
+	}
+	r, err := s.FindByKey(Key{"code": "B2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Int("version") != 1 || r.IsNull("created_at") || r.IsNull("updated_at") {
+		t.Errorf("inserted row = %v, want version 1 and timestamps", r)
+	}
+}
+
+func TestUpsertSQLite(t *testing.T) {
+	s := stockModel(t, testModel(t))
+	defer func(now func() time.Time) { timeNow = now }(timeNow)
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	timeNow = func() time.Time { return created }
+
+	if _, err := s.Upsert([]SQLField{{FieldName: "code", Value: "F1"}, {FieldName: "qty", Value: 1}}, []string{"code"}, []string{"qty"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the version is incremented and created_at kept, even when listed in updateFields
+	updated := created.Add(time.Hour)
+	timeNow = func() time.Time { return updated }
+	fields := []SQLField{{FieldName: "code", Value: "F1"}, {FieldName: "qty", Value: 5}}
+	res, err := s.Upsert(fields, []string{"code"}, []string{"qty", "version", "created_at"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.RowsAffected != 1 {
+		t.Errorf("Upsert RowsAffected = %d, want 1", res.RowsAffected)
+	}
+	r, err := s.FindByKey(Key{"code": "F1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Int("qty") != 5 || r.Int("version") != 2 || !r.Time("created_at").Equal(created) || !r.Time("updated_at").Equal(updated) {
+		t.Errorf("row after Upsert = %v, want qty 5, version 2, created %v, updated %v", r, created, updated)
+	}
+
+	// without updateFields the existing row is left unchanged
+	if _, err := s.Upsert([]SQLField{{FieldName: "code", Value: "F1"}, {FieldName: "qty", Value: 9}}, []string{"code"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if r, err = s.FindByKey(Key{"code": "F1"}); err != nil || r.Int("qty") != 5 || r.Int("version") != 2 {
+		t.Errorf("row after Upsert without updateFields = %v, %v, want qty 5, version 2", r, err)
+	}
+
+	if _, err := s.Upsert(fields, nil, []string{"qty"}); err == nil {
+		t.Error("Upsert without conflict keys succeeded on SQLite")
+	}
+	if _, err := s.Upsert(fields, []string{"code"}, []string{"qty; DROP TABLE stock"}); !errors.Is(err, ErrInvalidIdentifier) {
+		t.Errorf("Upsert of an invalid update field error = %v, want %v", err, ErrInvalidIdentifier)
+	}
+}
@@ -0,0 +1,226 @@
+package gomvc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// DefaultPerPage and MaxPerPage limit the page size of the built-in view action (?page=&per_page=)
+const (
+	DefaultPerPage int64 = 20
+	MaxPerPage     int64 = 1000
+)
+
+// Pagination is the paging information of a paginated query, available in templates as .Pagination.
+// For cursor (keyset) pagination Total and Pages are not counted and NextCursor holds the cursor of the next page.
+type Pagination struct {
+	Page       int64
+	PerPage    int64
+	Total      int64
+	Pages      int64
+	HasPrev    bool
+	HasNext    bool
+	PrevURL    string
+	NextURL    string
+	NextCursor string
+}
+
+// Paginate returns the rows of page (1 based) and the paging information, the total is counted with the same conditions
+func (qb *QueryBuilder) Paginate(page int64, perPage int64) ([]ResultRow, Pagination, error) {
+	return qb.PaginateContext(context.Background(), page, perPage)
+}
+
+// PaginateContext is Paginate with a context
+func (qb *QueryBuilder) PaginateContext(ctx context.Context, page int64, perPage int64) ([]ResultRow, Pagination, error) {
+	if perPage <= 0 {
+		return []ResultRow{}, Pagination{}, errors.New("per page must be greater than zero")
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	total, err := qb.countTotal(ctx)
+	if err != nil {
+		return []ResultRow{}, Pagination{}, err
+	}
+
+	p := Pagination{Page: page, PerPage: perPage, Total: total}
+	p.Pages = (total + perPage - 1) / perPage
+	p.HasPrev = page > 1
+	p.HasNext = page < p.Pages
+
+	pqb := *qb
+	pqb.Limit(perPage).Offset((page - 1) * perPage)
+	rows, err := pqb.ExecuteContext(ctx)
+	if err != nil {
+		return []ResultRow{}, Pagination{}, err
+	}
+
+	return rows, p, nil
+}
+
+// PaginateCursor returns the next perPage rows after cursor ordered by primary key (keyset pagination),
+// use "" for the first page and Pagination.NextCursor for the next one. Unlike Paginate the rows are not
+// counted and the cost of a page does not grow with the page number. The builder must not have ORDER BY, LIMIT or OFFSET.
+func (qb *QueryBuilder) PaginateCursor(cursor string, perPage int64) ([]ResultRow, Pagination, error) {
+	return qb.PaginateCursorContext(context.Background(), cursor, perPage)
+}
+
+// PaginateCursorContext is PaginateCursor with a context
+func (qb *QueryBuilder) PaginateCursorContext(ctx context.Context, cursor string, perPage int64) ([]ResultRow, Pagination, error) {
+	if perPage <= 0 {
+		return []ResultRow{}, Pagination{}, errors.New("per page must be greater than zero")
+	}
+	if len(qb.model.PKField) == 0 {
+		return []ResultRow{}, Pagination{}, errors.New("cursor pagination needs a model with primary key")
+	}
+	if len(qb.orderBy) > 0 || qb.limit > 0 || qb.offset > 0 {
+		return []ResultRow{}, Pagination{}, errors.New("cursor pagination does not support order by, limit and offset")
+	}
+
+	pk := qb.model.TableName + "." + qb.model.PKField
+	cqb := *qb
+	cqb.wheres = append([]Filter{}, qb.wheres...)
+	if len(cursor) > 0 {
+		if len(qb.wheres) > 0 {
+			cqb.wheres = []Filter{{Group: qb.wheres}}
+		}
+		cqb.addWhere(Filter{Field: pk, Operator: ">", Value: cursor}, "AND")
+	}
+	// one more row tells if there is a next page
+	cqb.OrderBy(pk, "ASC").Limit(perPage + 1)
+
+	rows, err := cqb.ExecuteContext(ctx)
+	if err != nil {
+		return []ResultRow{}, Pagination{}, err
+	}
+
+	p := Pagination{PerPage: perPage, HasPrev: len(cursor) > 0}
+	if int64(len(rows)) > perPage {
+		rows = rows[:perPage]
+		p.HasNext = true
+
+		idx := rows[len(rows)-1].GetFieldIndex(qb.model.PKField)
+		if idx < 0 {
+			return []ResultRow{}, Pagination{}, errors.New("cursor pagination: primary key " + qb.model.PKField + " is not selected")
+		}
+		p.NextCursor = fmt.Sprint(rows[len(rows)-1].Values[idx])
+	}
+
+	return rows, p, nil
+}
+
+// SetLinks sets PrevURL and NextURL from the request URL, the page (or cursor) query parameter is replaced
+func (p *Pagination) SetLinks(u *url.URL) {
+	link := func(key string, value string) string {
+		q := u.Query()
+		q.Del("page")
+		q.Del("cursor")
+		q.Set(key, value)
+		q.Set("per_page", strconv.FormatInt(p.PerPage, 10))
+		return u.Path + "?" + q.Encode()
+	}
+
+	p.PrevURL, p.NextURL = "", ""
+
+	// Cursor pagination has no page number and only a next link
+	if p.Page == 0 {
+		if p.HasNext {
+			p.NextURL = link("cursor", p.NextCursor)
+		}
+		return
+	}
+	if p.HasPrev {
+		p.PrevURL = link("page", strconv.FormatInt(p.Page-1, 10))
+	}
+	if p.HasNext {
+		p.NextURL = link("page", strconv.FormatInt(p.Page+1, 10))
+	}
+}
+
+// countTotal counts the rows matching the builder conditions, limit / offset / order are ignored
+// and grouped queries are counted as a subquery
+func (qb *QueryBuilder) countTotal(ctx context.Context) (int64, error) {
+	cqb := *qb
+	cqb.orderBy, cqb.limit, cqb.offset = "", 0, 0
+
+	var q string
+	var values []interface{}
+	var err error
+	if len(cqb.groupBy) > 0 || len(cqb.having) > 0 {
+		q, values, err = cqb.buildStatement()
+		if err != nil {
+			return 0, err
+		}
+		q = "SELECT COUNT(*) AS count FROM (" + q + ") gomvc_count"
+	} else {
+		cqb.selectCols = []string{"COUNT(*) AS count"}
+		q, values, err = cqb.buildStatement()
+		if err != nil {
+			return 0, err
+		}
+	}
+	q = rebind(qb.model.dialect(), q)
+
+	qctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	var count int64
+	if err := qb.model.conn().QueryRowContext(qctx, q, values...).Scan(&count); err != nil {
+		InfoMessage("Query failed: " + q)
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// pageParams reads the page, per_page and cursor URL parameters, paged is false when none is given.
+// page is 0 for cursor pagination (?cursor=), invalid values fall back to the defaults.
+func pageParams(params map[string][]interface{}) (page int64, perPage int64, cursor string, paged bool) {
+	value := func(key string) (string, bool) {
+		v, ok := params[key]
+		if !ok || len(v) == 0 {
+			return "", ok
+		}
+		s, _ := v[0].(string)
+		return s, true
+	}
+
+	page, perPage = 1, DefaultPerPage
+
+	if v, ok := value("page"); ok {
+		paged = true
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			page = n
+		}
+	}
+	if v, ok := value("per_page"); ok {
+		paged = true
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n > 0 {
+			perPage = n
+		}
+		if perPage > MaxPerPage {
+			perPage = MaxPerPage
+		}
+	}
+	if v, ok := value("cursor"); ok {
+		paged = true
+		cursor, page = v, 0
+	}
+
+	return page, perPage, cursor, paged
+}
+
+// recordsQueryBuilder returns a query builder with the joins of the ResultStyleFullresult relations, same as GetRecords
+func (m *Model) recordsQueryBuilder() *QueryBuilder {
+	qb := m.NewQueryBuilder()
+	for _, rel := range m.Relations {
+		if rel.ResultStyle == ResultStyleFullresult {
+			qb.joins = append(qb.joins, rel.Join)
+		}
+	}
+	return qb
+}
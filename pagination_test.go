@@ -0,0 +1,115 @@
+package gomvc
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestPaginateSQLite(t *testing.T) {
+	m := testModel(t)
+	insertCars(t, m, "a", "b", "c", "d", "e", "ford")
+
+	rows, p, err := m.NewQueryBuilder().Where("name", "<>", "ford").OrderBy("id", "ASC").Paginate(2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0].String("name") != "c" {
+		t.Errorf("page 2 = %v, want c, d", rows)
+	}
+	if want := (Pagination{Page: 2, PerPage: 2, Total: 5, Pages: 3, HasPrev: true, HasNext: true}); p != want {
+		t.Errorf("Pagination = %+v, want %+v", p, want)
+	}
+
+	rows, p, err = m.NewQueryBuilder().Where("name", "<>", "ford").OrderBy("id", "ASC").Paginate(3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || p.HasNext {
+		t.Errorf("last page = %v, %+v, want 1 row without next page", rows, p)
+	}
+
+	// grouped queries count the groups
+	_, p, err = m.NewQueryBuilder().Select("price").GroupBy("price").Paginate(1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Total != 1 || p.Pages != 1 {
+		t.Errorf("grouped Pagination = %+v, want 1 group", p)
+	}
+
+	if _, _, err := m.NewQueryBuilder().Paginate(1, 0); err == nil {
+		t.Error("Paginate with 0 per page succeeded")
+	}
+}
+
+func TestPaginateCursorSQLite(t *testing.T) {
+	m := testModel(t)
+	insertCars(t, m, "a", "b", "c", "d", "e")
+
+	names := make([]string, 0)
+	cursor, pages := "", 0
+	for {
+		rows, p, err := m.NewQueryBuilder().Where("name", "<>", "b").PaginateCursor(cursor, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		for _, r := range rows {
+			names = append(names, r.String("name"))
+		}
+		if !p.HasNext {
+			break
+		}
+		cursor = p.NextCursor
+	}
+	if pages != 2 || len(names) != 4 || names[0] != "a" || names[1] != "c" || names[3] != "e" {
+		t.Errorf("cursor pages = %d, names = %v, want 2 pages of a c d e", pages, names)
+	}
+
+	if _, _, err := m.NewQueryBuilder().OrderBy("name", "ASC").PaginateCursor("", 2); err == nil {
+		t.Error("PaginateCursor with ORDER BY succeeded")
+	}
+}
+
+func TestPaginationSetLinks(t *testing.T) {
+	u, _ := url.Parse("/cars/view?name=ford&page=2")
+
+	p := Pagination{Page: 2, PerPage: 10, HasPrev: true, HasNext: true}
+	p.SetLinks(u)
+	if p.PrevURL != "/cars/view?name=ford&page=1&per_page=10" || p.NextURL != "/cars/view?name=ford&page=3&per_page=10" {
+		t.Errorf("links = %q, %q", p.PrevURL, p.NextURL)
+	}
+
+	p = Pagination{PerPage: 10, HasPrev: true, HasNext: true, NextCursor: "42"}
+	p.SetLinks(u)
+	if p.PrevURL != "" || p.NextURL != "/cars/view?cursor=42&name=ford&per_page=10" {
+		t.Errorf("cursor links = %q, %q", p.PrevURL, p.NextURL)
+	}
+}
+
+func TestPageParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string][]interface{}
+		page    int64
+		perPage int64
+		cursor  string
+		paged   bool
+	}{
+		{"none", map[string][]interface{}{}, 1, DefaultPerPage, "", false},
+		{"page", map[string][]interface{}{"page": {"3"}, "per_page": {"5"}}, 3, 5, "", true},
+		{"invalid", map[string][]interface{}{"page": {"x"}, "per_page": {"-1"}}, 1, DefaultPerPage, "", true},
+		{"max per page", map[string][]interface{}{"per_page": {"100000"}}, 1, MaxPerPage, "", true},
+		{"cursor", map[string][]interface{}{"cursor": {"7"}}, 0, DefaultPerPage, "7", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, perPage, cursor, paged := pageParams(tt.params)
+			if page != tt.page || perPage != tt.perPage || cursor != tt.cursor || paged != tt.paged {
+				t.Errorf("pageParams = %d, %d, %q, %v, want %d, %d, %q, %v",
+					page, perPage, cursor, paged, tt.page, tt.perPage, tt.cursor, tt.paged)
+			}
+		})
+	}
+}
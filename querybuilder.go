@@ -1,22 +1,32 @@
 package gomvc
 
 import (
+	"context"
 	"errors"
 	"strings"
 )
 
-// QueryBuilder provides a safe way to build complex queries
+// QueryBuilder provides a safe way to build complex queries, identifiers are validated against the model columns
+// and quoted, values are parameterized. The first invalid identifier / operator is returned by Execute, First, Count ...
 type QueryBuilder struct {
 	model      *Model
 	selectCols []string
 	joins      []SQLJoin
 	wheres     []Filter
 	groupBy    string
+	having     []Filter
 	orderBy    string
 	limit      int64
 	offset     int64
+	err        error
+	trashed    trashedScope
+
+	allowUnfiltered bool
 }
 
+// ErrUnfilteredWrite is returned by QueryBuilder.Update / Delete without WHERE conditions, see AllowUnfiltered
+var ErrUnfilteredWrite = errors.New("refusing to update / delete all rows without a WHERE condition, use AllowUnfiltered()")
+
 // NewQueryBuilder creates a new query builder for a model
 func (m *Model) NewQueryBuilder() *QueryBuilder {
 	return &QueryBuilder{
@@ -24,6 +34,7 @@ func (m *Model) NewQueryBuilder() *QueryBuilder {
 		selectCols: []string{"*"},
 		joins:      make([]SQLJoin, 0),
 		wheres:     make([]Filter, 0),
+		trashed:    m.trashed,
 	}
 }
 
@@ -57,52 +68,113 @@ func (qb *QueryBuilder) LeftJoin(foreignTable, foreignPK, localKey, foreignKey s
 
 // Where adds a WHERE condition with AND logic
 func (qb *QueryBuilder) Where(field, operator string, value interface{}) *QueryBuilder {
-	logic := ""
-	if len(qb.wheres) > 0 {
-		logic = "AND"
-	}
-	qb.wheres = append(qb.wheres, Filter{
-		Field:    field,
-		Operator: operator,
-		Value:    value,
-		Logic:    logic,
-	})
-	return qb
+	return qb.addWhere(Filter{Field: field, Operator: operator, Value: value}, "AND")
 }
 
 // OrWhere adds a WHERE condition with OR logic
 func (qb *QueryBuilder) OrWhere(field, operator string, value interface{}) *QueryBuilder {
-	qb.wheres = append(qb.wheres, Filter{
-		Field:    field,
-		Operator: operator,
-		Value:    value,
-		Logic:    "OR",
-	})
-	return qb
+	return qb.addWhere(Filter{Field: field, Operator: operator, Value: value}, "OR")
 }
 
 // WhereIn adds a WHERE IN condition
 func (qb *QueryBuilder) WhereIn(field string, values []interface{}) *QueryBuilder {
-	logic := ""
+	return qb.addWhere(Filter{Field: field, Operator: "IN", Value: values}, "AND")
+}
+
+// WhereNotIn adds a WHERE NOT IN condition
+func (qb *QueryBuilder) WhereNotIn(field string, values []interface{}) *QueryBuilder {
+	return qb.addWhere(Filter{Field: field, Operator: "NOT IN", Value: values}, "AND")
+}
+
+// WhereNull adds a WHERE field IS NULL condition
+func (qb *QueryBuilder) WhereNull(field string) *QueryBuilder {
+	return qb.addWhere(Filter{Field: field, Operator: "IS NULL"}, "AND")
+}
+
+// WhereNotNull adds a WHERE field IS NOT NULL condition
+func (qb *QueryBuilder) WhereNotNull(field string) *QueryBuilder {
+	return qb.addWhere(Filter{Field: field, Operator: "IS NOT NULL"}, "AND")
+}
+
+// WhereBetween adds a WHERE field BETWEEN from AND to condition
+func (qb *QueryBuilder) WhereBetween(field string, from interface{}, to interface{}) *QueryBuilder {
+	return qb.addWhere(Filter{Field: field, Operator: "BETWEEN", Value: []interface{}{from, to}}, "AND")
+}
+
+// WhereGroup adds conditions in parentheses with AND logic, e.g. a = 1 AND (b = 2 OR c = 3):
+//
+//	qb.Where("a", "=", 1).WhereGroup(func(g *QueryBuilder) { g.Where("b", "=", 2).OrWhere("c", "=", 3) })
+func (qb *QueryBuilder) WhereGroup(fn func(qb *QueryBuilder)) *QueryBuilder {
+	return qb.addGroup(fn, "AND")
+}
+
+// OrWhereGroup adds conditions in parentheses with OR logic
+func (qb *QueryBuilder) OrWhereGroup(fn func(qb *QueryBuilder)) *QueryBuilder {
+	return qb.addGroup(fn, "OR")
+}
+
+// WhereExists adds a WHERE EXISTS (subquery) condition, use WhereRaw in the subquery for correlated conditions:
+//
+//	qb.WhereExists(parts.NewQueryBuilder().Select("id").WhereRaw("parts.car_id = cars.id"))
+func (qb *QueryBuilder) WhereExists(sub *QueryBuilder) *QueryBuilder {
+	return qb.addWhere(Filter{Operator: "EXISTS", Value: sub}, "AND")
+}
+
+// WhereNotExists adds a WHERE NOT EXISTS (subquery) condition
+func (qb *QueryBuilder) WhereNotExists(sub *QueryBuilder) *QueryBuilder {
+	return qb.addWhere(Filter{Operator: "NOT EXISTS", Value: sub}, "AND")
+}
+
+// WhereRaw adds a raw SQL condition with AND logic, values are passed as ? placeholders.
+// The expression is not validated, never build it from user input.
+func (qb *QueryBuilder) WhereRaw(expr string, args ...interface{}) *QueryBuilder {
+	return qb.addWhere(Filter{Raw: expr, Value: args}, "AND")
+}
+
+// OrWhereRaw adds a raw SQL condition with OR logic
+func (qb *QueryBuilder) OrWhereRaw(expr string, args ...interface{}) *QueryBuilder {
+	return qb.addWhere(Filter{Raw: expr, Value: args}, "OR")
+}
+
+// addWhere appends a condition, the logic of the first condition is ignored
+func (qb *QueryBuilder) addWhere(f Filter, logic string) *QueryBuilder {
 	if len(qb.wheres) > 0 {
-		logic = "AND"
-	}
-	// Create placeholder for IN clause
-	qb.wheres = append(qb.wheres, Filter{
-		Field:    field,
-		Operator: "IN",
-		Value:    values,
-		Logic:    logic,
-	})
+		f.Logic = logic
+	}
+	qb.wheres = append(qb.wheres, f)
 	return qb
 }
 
+// addGroup appends the conditions added by fn as a nested group
+func (qb *QueryBuilder) addGroup(fn func(qb *QueryBuilder), logic string) *QueryBuilder {
+	g := qb.model.NewQueryBuilder()
+	fn(g)
+	if g.err != nil {
+		qb.setErr(g.err)
+		return qb
+	}
+	if len(g.wheres) == 0 {
+		return qb
+	}
+	return qb.addWhere(Filter{Group: g.wheres}, logic)
+}
+
 // GroupBy adds GROUP BY clause
 func (qb *QueryBuilder) GroupBy(columns ...string) *QueryBuilder {
 	qb.groupBy = "GROUP BY " + strings.Join(columns, ", ")
 	return qb
 }
 
+// Having adds a HAVING condition with AND logic, field can be a column or an aggregate (COUNT(*), SUM(price) ...)
+func (qb *QueryBuilder) Having(field, operator string, value interface{}) *QueryBuilder {
+	f := Filter{Field: field, Operator: operator, Value: value}
+	if len(qb.having) > 0 {
+		f.Logic = "AND"
+	}
+	qb.having = append(qb.having, f)
+	return qb
+}
+
 // OrderBy adds ORDER BY clause
 func (qb *QueryBuilder) OrderBy(column, direction string) *QueryBuilder {
 	if qb.orderBy == "" {
@@ -110,7 +182,12 @@ func (qb *QueryBuilder) OrderBy(column, direction string) *QueryBuilder {
 	} else {
 		qb.orderBy += ", "
 	}
-	qb.orderBy += column + " " + strings.ToUpper(direction)
+	dir, err := normalizeDirection(direction)
+	if err != nil {
+		qb.setErr(err)
+		return qb
+	}
+	qb.orderBy += column + " " + dir
 	return qb
 }
 
@@ -126,50 +203,147 @@ func (qb *QueryBuilder) Offset(offset int64) *QueryBuilder {
 	return qb
 }
 
+// Err returns the first error of the builder methods (invalid order direction ...)
+func (qb *QueryBuilder) Err() error {
+	return qb.err
+}
+
+// setErr keeps the first builder error
+func (qb *QueryBuilder) setErr(err error) {
+	if qb.err == nil {
+		qb.err = err
+	}
+}
+
+// checkColumns verifies the select, where, group and order columns against the model columns
+func (qb *QueryBuilder) checkColumns(d Dialect) error {
+	for _, col := range qb.selectCols {
+		_, c, err := quoteSelectExpr(d, col)
+		if err != nil {
+			return err
+		}
+		if err := qb.model.checkColumn(c); err != nil {
+			return err
+		}
+	}
+
+	if err := qb.model.checkFilters(qb.wheres); err != nil {
+		return err
+	}
+
+	for _, f := range qb.having {
+		if len(f.Group) > 0 || len(f.Raw) > 0 {
+			continue
+		}
+		_, c, err := quoteSelectExpr(d, f.Field)
+		if err != nil {
+			return err
+		}
+		if err := qb.model.checkColumn(c); err != nil {
+			return err
+		}
+	}
+
+	groupCols, err := parseGroupBy(qb.groupBy)
+	if err != nil {
+		return err
+	}
+	for _, c := range groupCols {
+		if err := qb.model.checkColumn(c); err != nil {
+			return err
+		}
+	}
+
+	terms, err := parseOrderBy(qb.orderBy)
+	if err != nil {
+		return err
+	}
+	for _, t := range terms {
+		if err := qb.model.checkColumn(t.column); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // buildQuery constructs the SQL query with proper parameterization
-func (qb *QueryBuilder) buildQuery() (string, []interface{}) {
+func (qb *QueryBuilder) buildQuery() (string, []interface{}, error) {
+	q, values, err := qb.buildStatement()
+	if err != nil {
+		return "", nil, err
+	}
+	return rebind(qb.model.dialect(), q), values, nil
+}
+
+// buildStatement constructs the SQL query with ? placeholders, used as is for subqueries
+func (qb *QueryBuilder) buildStatement() (string, []interface{}, error) {
+	if qb.err != nil {
+		return "", nil, qb.err
+	}
+
+	d := qb.model.dialect()
+	if err := qb.checkColumns(d); err != nil {
+		return "", nil, err
+	}
+
 	fields := make([]SQLField, 0)
 	for _, col := range qb.selectCols {
 		fields = append(fields, SQLField{FieldName: col})
 	}
 
-	// Modify BuildQuery to handle IN clauses and OFFSET
-	q, values := BuildQueryExtended(
+	return buildStatement(
+		d,
 		QueryTypeSelect,
 		fields,
 		SQLTable{TableName: qb.model.TableName, PKField: qb.model.PKField},
 		qb.joins,
-		qb.wheres,
+		qb.model.scopeFilters(qb.wheres, qb.trashed),
 		qb.groupBy,
+		qb.having,
 		qb.orderBy,
 		qb.limit,
 		qb.offset,
 	)
-
-	return q, values
 }
 
 // Execute executes the query and returns results
 func (qb *QueryBuilder) Execute() ([]ResultRow, error) {
-	q, values := qb.buildQuery()
+	return qb.ExecuteContext(context.Background())
+}
+
+// ExecuteContext executes the query with a context, the query is cancelled when ctx is done
+func (qb *QueryBuilder) ExecuteContext(ctx context.Context) ([]ResultRow, error) {
+	q, values, err := qb.buildQuery()
+	if err != nil {
+		return []ResultRow{}, err
+	}
 
 	qb.model.lastQuery = q
 	qb.model.lastValues = values
 
-	r, err := qb.model.DB.Query(q, values...)
+	qctx, cancel := queryContext(ctx)
+	defer cancel()
+
+	r, err := qb.model.conn().QueryContext(qctx, q, values...)
 	if err != nil {
 		InfoMessage("Query failed: " + q)
 		return []ResultRow{}, err
 	}
 	defer r.Close()
 
-	return qb.model.scanRows(r)
+	return qb.model.scanRows(ctx, r)
 }
 
 // First executes the query and returns the first result
 func (qb *QueryBuilder) First() (ResultRow, error) {
+	return qb.FirstContext(context.Background())
+}
+
+// FirstContext is First with a context
+func (qb *QueryBuilder) FirstContext(ctx context.Context) (ResultRow, error) {
 	qb.Limit(1)
-	results, err := qb.Execute()
+	results, err := qb.ExecuteContext(ctx)
 	if err != nil {
 		return ResultRow{}, err
 	}
@@ -181,21 +355,107 @@ func (qb *QueryBuilder) First() (ResultRow, error) {
 
 // Count returns the count of matching records
 func (qb *QueryBuilder) Count() (int64, error) {
-	qb.selectCols = []string{"COUNT(*) as count"}
-	result, err := qb.First()
-	if err != nil {
-		return 0, err
+	return qb.CountContext(context.Background())
+}
+
+// CountContext is Count with a context, limit, offset and order are ignored
+func (qb *QueryBuilder) CountContext(ctx context.Context) (int64, error) {
+	return qb.countTotal(ctx)
+}
+
+// AllowUnfiltered allows Update / Delete without WHERE conditions (all rows of the table)
+func (qb *QueryBuilder) AllowUnfiltered() *QueryBuilder {
+	qb.allowUnfiltered = true
+	return qb
+}
+
+// Update updates all rows matching the WHERE conditions and returns the rows affected
+func (qb *QueryBuilder) Update(fields []SQLField) (WriteResult, error) {
+	return qb.UpdateContext(context.Background(), fields)
+}
+
+// UpdateContext is Update with a context
+func (qb *QueryBuilder) UpdateContext(ctx context.Context, fields []SQLField) (WriteResult, error) {
+	if len(fields) == 0 {
+		return WriteResult{}, errors.New("no fields to update")
 	}
 
-	countIdx := result.GetFieldIndex("count")
-	if countIdx == -1 {
-		return 0, errors.New("count field not found")
+	return qb.model.withHooks(ctx, &HookContext{Event: BeforeUpdate, Fields: fields}, func(tm *Model, fields []SQLField) (WriteResult, error) {
+		tqb := *qb
+		tqb.model = tm
+		return tqb.update(ctx, fields)
+	})
+}
+
+// update executes the UPDATE statement, hooks are not run
+func (qb *QueryBuilder) update(ctx context.Context, fields []SQLField) (WriteResult, error) {
+	fields = qb.model.withTimestamps(fields, false)
+	if err := qb.model.checkFields(fields); err != nil {
+		return WriteResult{}, err
+	}
+	if len(qb.model.VersionField) > 0 {
+		// bulk updates increment the version without checking it
+		fields, _, _ = qb.model.withVersion(fields)
 	}
+	return qb.write(ctx, QueryTypeUpdate, fields)
+}
+
+// Delete deletes all rows matching the WHERE conditions and returns the rows affected,
+// models with SoftDeleteField are soft deleted, see ForceDelete
+func (qb *QueryBuilder) Delete() (WriteResult, error) {
+	return qb.DeleteContext(context.Background())
+}
+
+// DeleteContext is Delete with a context
+func (qb *QueryBuilder) DeleteContext(ctx context.Context) (WriteResult, error) {
+	return qb.model.withHooks(ctx, &HookContext{Event: BeforeDelete}, func(tm *Model, fields []SQLField) (WriteResult, error) {
+		tqb := *qb
+		tqb.model = tm
+		return tqb.delete(ctx, false)
+	})
+}
+
+// delete executes the DELETE statement or the soft delete UPDATE statement, hooks are not run
+func (qb *QueryBuilder) delete(ctx context.Context, force bool) (WriteResult, error) {
+	if len(qb.model.SoftDeleteField) > 0 && !force {
+		fields := qb.model.withTimestamps([]SQLField{{FieldName: qb.model.SoftDeleteField, Value: timeNow()}}, false)
+		return qb.write(ctx, QueryTypeUpdate, fields)
+	}
+	return qb.write(ctx, QueryTypeDelete, []SQLField{})
+}
 
-	count, ok := result.Values[countIdx].(int64)
-	if !ok {
-		return 0, errors.New("invalid count value")
+// write executes an UPDATE / DELETE statement with the builder WHERE conditions
+func (qb *QueryBuilder) write(ctx context.Context, queryType QueryType, fields []SQLField) (WriteResult, error) {
+	if qb.err != nil {
+		return WriteResult{}, qb.err
 	}
+	if len(qb.wheres) == 0 && !qb.allowUnfiltered {
+		return WriteResult{}, ErrUnfilteredWrite
+	}
+	if len(qb.joins) > 0 || len(qb.groupBy) > 0 || len(qb.having) > 0 || len(qb.orderBy) > 0 || qb.limit > 0 || qb.offset > 0 {
+		return WriteResult{}, errors.New("joins, group by, order by, limit and offset are not supported by Update / Delete")
+	}
+	if err := qb.model.checkFilters(qb.wheres); err != nil {
+		return WriteResult{}, err
+	}
+
+	q, values, err := buildQuery(qb.model.dialect(), queryType, fields,
+		SQLTable{TableName: qb.model.TableName, PKField: qb.model.PKField}, []SQLJoin{}, qb.model.scopeFilters(qb.wheres, qb.trashed), "", "", 0, 0)
+	if err != nil {
+		return WriteResult{}, err
+	}
+
+	qb.model.lastQuery = q
+	qb.model.lastValues = values
+
+	res, err := executeWithContext(ctx, qb.model, q, values)
+	if err != nil {
+		InfoMessage("Query failed: " + q)
+		return WriteResult{}, err
+	}
+
+	// LastInsertId is meaningful only for INSERT
+	res.LastInsertId = 0
 
-	return count, nil
+	return res, nil
 }
@@ -0,0 +1,57 @@
+package gomvc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestQueryBuilderSQLite(t *testing.T) {
+	m := testModel(t)
+	insertCars(t, m, "ford", "bmw", "audi", "fiat")
+
+	rr, err := m.NewQueryBuilder().Where("name", "LIKE", "f%").OrderBy("name", "DESC").Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rr) != 2 || rr[0].String("name") != "ford" || rr[1].String("name") != "fiat" {
+		t.Errorf("LIKE f%% = %v, want ford, fiat", rr)
+	}
+
+	rr, err = m.NewQueryBuilder().OrderBy("id", "ASC").Limit(2).Offset(1).Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rr) != 2 || rr[0].Int("id") != 2 || rr[1].Int("id") != 3 {
+		t.Errorf("LIMIT 2 OFFSET 1 = %v, want ids 2, 3", rr)
+	}
+
+	n, err := m.NewQueryBuilder().WhereIn("id", []interface{}{1, 3, 9}).Count()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("Count IN (1, 3, 9) = %d, want 2", n)
+	}
+
+	res, err := m.NewQueryBuilder().Where("name", "=", "bmw").Update([]SQLField{{FieldName: "name", Value: "BMW"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.RowsAffected != 1 {
+		t.Errorf("Update RowsAffected = %d, want 1", res.RowsAffected)
+	}
+
+	if _, err := m.NewQueryBuilder().WhereBetween("id", 3, 4).Delete(); err != nil {
+		t.Fatal(err)
+	}
+	if got := carNames(t, m); len(got) != 2 || got[1] != "BMW" {
+		t.Errorf("cars = %v, want [ford BMW]", got)
+	}
+
+	if _, err := m.NewQueryBuilder().Delete(); !errors.Is(err, ErrUnfilteredWrite) {
+		t.Errorf("unfiltered delete error = %v, want %v", err, ErrUnfilteredWrite)
+	}
+	if _, err := m.NewQueryBuilder().Where("name", "= 1 OR", "x").Execute(); !errors.Is(err, ErrInvalidOperator) {
+		t.Errorf("invalid operator error = %v, want %v", err, ErrInvalidOperator)
+	}
+}
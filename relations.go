@@ -0,0 +1,391 @@
+package gomvc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// RelationType is the kind of a relation between two models
+type RelationType int
+
+const (
+	// RelationHasMany foreign rows reference the local row: foreign.ForeignKey = local.LocalKey (default)
+	RelationHasMany RelationType = 0
+	// RelationHasOne same as has-many with at most one foreign row
+	RelationHasOne RelationType = 1
+	// RelationBelongsTo the local row references the foreign row: local.LocalKey = foreign.ForeignKey
+	RelationBelongsTo RelationType = 2
+	// RelationManyToMany local and foreign rows are linked by the rows of a pivot table
+	RelationManyToMany RelationType = 3
+)
+
+var relationTypeNames = map[RelationType]string{
+	RelationHasMany: "has-many", RelationHasOne: "has-one", RelationBelongsTo: "belongs-to", RelationManyToMany: "many-to-many",
+}
+
+// String returns the relation type name, e.g. belongs-to
+func (t RelationType) String() string {
+	if n, ok := relationTypeNames[t]; ok {
+		return n
+	}
+	return "RelationType(" + strconv.Itoa(int(t)) + ")"
+}
+
+// relationBatchSize is the max number of keys in the IN (...) list of a relation query
+const relationBatchSize = 500
+
+// AddModelRelation adds a relation to an already initialized model, use it for nested relations:
+// the relations of fm are loaded recursively when fm is a ResultStyleSubresult relation.
+func (m *Model) AddModelRelation(fm *Model, keys SQLKeyPair, join_type JoinType, result_style ResultStyle) {
+	m.addRelation(fm, keys, SQLPivot{}, join_type, result_style, RelationHasMany)
+}
+
+// AddHasOneRelation adds a has-one relation, keys.ForeignKey is the column of the foreign table referencing keys.LocalKey
+func (m *Model) AddHasOneRelation(fm *Model, keys SQLKeyPair, join_type JoinType, result_style ResultStyle) {
+	m.addRelation(fm, keys, SQLPivot{}, join_type, result_style, RelationHasOne)
+}
+
+// AddBelongsToRelation adds a belongs-to relation, keys.LocalKey is the column of the local table referencing
+// keys.ForeignKey (usually the foreign primary key), e.g. cars.brand_id -> brands.id
+func (m *Model) AddBelongsToRelation(fm *Model, keys SQLKeyPair, join_type JoinType, result_style ResultStyle) {
+	m.addRelation(fm, keys, SQLPivot{}, join_type, result_style, RelationBelongsTo)
+}
+
+// AddManyToManyRelation adds a many-to-many relation through a pivot table, the pivot columns reference
+// the primary keys of both models, e.g. posts.id <- post_tags.post_id, post_tags.tag_id -> tags.id
+func (m *Model) AddManyToManyRelation(fm *Model, pivot SQLPivot, join_type JoinType, result_style ResultStyle) {
+	m.addRelation(fm, SQLKeyPair{LocalKey: m.PKField, ForeignKey: fm.PKField}, pivot, join_type, result_style, RelationManyToMany)
+}
+
+// addRelation appends a relation to an initialized foreign model
+func (m *Model) addRelation(fm *Model, keys SQLKeyPair, pivot SQLPivot, join_type JoinType, result_style ResultStyle, t RelationType) {
+	if m.Relations == nil {
+		m.Relations = make([]Relation, 0)
+	}
+	m.Relations = append(m.Relations,
+		Relation{Join: SQLJoin{Foreign_table: fm.TableName, Foreign_PK: fm.PKField, KeyPair: keys, Join_type: join_type, Pivot: pivot},
+			Foreign_model: *fm,
+			ResultStyle:   result_style,
+			Type:          t},
+	)
+}
+
+// loadRelations fills the Subresult of rows for every ResultStyleSubresult relation,
+// the parent keys are collected and every relation is loaded with WHERE foreign_key IN (...) queries
+// instead of one query per row. Relations of the foreign model are loaded the same way.
+func (m *Model) loadRelations(ctx context.Context, rows []ResultRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	for _, relation := range m.Relations {
+		if relation.ResultStyle != ResultStyleSubresult {
+			continue
+		}
+
+		keys := distinctKeys(rows, relation.Join.KeyPair.LocalKey)
+		if len(keys) == 0 {
+			continue
+		}
+
+		fm := relation.Foreign_model
+		fm.tx = m.tx
+
+		var groups map[string][]ResultRow
+		var err error
+		if relation.Type == RelationManyToMany {
+			groups, err = m.loadPivotGroups(ctx, &fm, relation.Join, keys)
+		} else {
+			groups, err = loadKeyGroups(ctx, &fm, relation.Join.KeyPair.ForeignKey, keys)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Stitch the related rows to their parents
+		for i := range rows {
+			idx := rows[i].GetFieldIndex(relation.Join.KeyPair.LocalKey)
+			if idx < 0 || idx >= len(rows[i].Values) || rows[i].Values[idx] == nil {
+				continue
+			}
+			g := groups[relationKey(rows[i].Values[idx])]
+			if (relation.Type == RelationHasOne || relation.Type == RelationBelongsTo) && len(g) > 1 {
+				g = g[:1]
+			}
+			rows[i].Subresult = append(rows[i].Subresult, g...)
+		}
+	}
+
+	return nil
+}
+
+// loadKeyGroups loads the rows of fm with field IN (keys) in chunks and groups them by field
+func loadKeyGroups(ctx context.Context, fm *Model, field string, keys []interface{}) (map[string][]ResultRow, error) {
+	groups := make(map[string][]ResultRow)
+	for start := 0; start < len(keys); start += relationBatchSize {
+		end := start + relationBatchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		f := []Filter{{Field: field, Operator: "IN", Value: keys[start:end]}}
+		rel_rr, err := fm.GetRecordsContext(ctx, f, 0)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, fr := range rel_rr {
+			idx := fr.GetFieldIndex(field)
+			if idx < 0 || idx >= len(fr.Values) {
+				continue
+			}
+			k := relationKey(fr.Values[idx])
+			groups[k] = append(groups[k], fr)
+		}
+	}
+	return groups, nil
+}
+
+// loadPivotGroups loads the pivot rows of the local keys and then the foreign rows they reference,
+// the foreign rows are grouped by local key
+func (m *Model) loadPivotGroups(ctx context.Context, fm *Model, join SQLJoin, keys []interface{}) (map[string][]ResultRow, error) {
+	pivotRows, err := loadKeyGroups(ctx, m.pivotModel(join.Pivot), join.Pivot.LocalKey, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	foreignKeys := make([]interface{}, 0)
+	for _, prs := range pivotRows {
+		foreignKeys = append(foreignKeys, distinctKeys(prs, join.Pivot.ForeignKey)...)
+	}
+	foreignKeys = distinctValues(foreignKeys)
+
+	byForeignKey := make(map[string][]ResultRow)
+	if len(foreignKeys) > 0 {
+		byForeignKey, err = loadKeyGroups(ctx, fm, join.KeyPair.ForeignKey, foreignKeys)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	groups := make(map[string][]ResultRow)
+	for k, prs := range pivotRows {
+		for _, pr := range prs {
+			idx := pr.GetFieldIndex(join.Pivot.ForeignKey)
+			if idx < 0 || pr.Values[idx] == nil {
+				continue
+			}
+			groups[k] = append(groups[k], byForeignKey[relationKey(pr.Values[idx])]...)
+		}
+	}
+
+	return groups, nil
+}
+
+// pivotModel returns a model of the pivot table sharing the connection and transaction of m
+func (m *Model) pivotModel(pivot SQLPivot) *Model {
+	return &Model{DB: m.DB, Dialect: m.Dialect, TableName: pivot.Table, tx: m.tx}
+}
+
+// manyToManyRelation returns the many-to-many relation to the foreign table
+func (m *Model) manyToManyRelation(foreignTable string) (Relation, error) {
+	for _, r := range m.Relations {
+		if r.Type == RelationManyToMany && r.Join.Foreign_table == foreignTable {
+			return r, nil
+		}
+	}
+	return Relation{}, errors.New("no many-to-many relation to table: " + foreignTable)
+}
+
+// Attach links the record id to the foreign records of a many-to-many relation, existing links are skipped.
+// The links are inserted in one transaction (a savepoint when the model is bound to a transaction)
+func (m *Model) Attach(foreignTable string, id interface{}, foreignIds ...interface{}) (WriteResult, error) {
+	return m.AttachContext(context.Background(), foreignTable, id, foreignIds...)
+}
+
+// AttachContext is Attach with a context
+func (m *Model) AttachContext(ctx context.Context, foreignTable string, id interface{}, foreignIds ...interface{}) (WriteResult, error) {
+	if m == nil {
+		return WriteResult{}, errors.New("cannot perform action: Attach() on nil model")
+	}
+
+	rel, err := m.manyToManyRelation(foreignTable)
+	if err != nil {
+		return WriteResult{}, err
+	}
+
+	var res WriteResult
+	err = m.inTx(ctx, func(tm *Model) error {
+		attached, err := tm.attachedKeys(ctx, rel.Join.Pivot, id)
+		if err != nil {
+			return err
+		}
+
+		pm := tm.pivotModel(rel.Join.Pivot)
+		for _, fid := range distinctValues(foreignIds) {
+			if _, ok := attached[relationKey(fid)]; ok {
+				continue
+			}
+			q, values, err := buildQuery(pm.dialect(), QueryTypeInsert,
+				[]SQLField{{FieldName: rel.Join.Pivot.LocalKey, Value: id}, {FieldName: rel.Join.Pivot.ForeignKey, Value: fid}},
+				SQLTable{TableName: pm.TableName}, []SQLJoin{}, []Filter{}, "", "", 0, 0)
+			if err != nil {
+				return err
+			}
+			r, err := executeWithContext(ctx, pm, q, values)
+			if err != nil {
+				return err
+			}
+			res.RowsAffected += r.RowsAffected
+		}
+		return nil
+	})
+	if err != nil {
+		return WriteResult{}, err
+	}
+
+	return res, nil
+}
+
+// Detach removes the links of the record id to the foreign records, without foreignIds all links are removed
+func (m *Model) Detach(foreignTable string, id interface{}, foreignIds ...interface{}) (WriteResult, error) {
+	return m.DetachContext(context.Background(), foreignTable, id, foreignIds...)
+}
+
+// DetachContext is Detach with a context
+func (m *Model) DetachContext(ctx context.Context, foreignTable string, id interface{}, foreignIds ...interface{}) (WriteResult, error) {
+	if m == nil {
+		return WriteResult{}, errors.New("cannot perform action: Detach() on nil model")
+	}
+
+	rel, err := m.manyToManyRelation(foreignTable)
+	if err != nil {
+		return WriteResult{}, err
+	}
+
+	f := []Filter{{Field: rel.Join.Pivot.LocalKey, Operator: "=", Value: id}}
+	if len(foreignIds) > 0 {
+		f = append(f, Filter{Field: rel.Join.Pivot.ForeignKey, Operator: "IN", Value: foreignIds, Logic: "AND"})
+	}
+
+	pm := m.pivotModel(rel.Join.Pivot)
+	q, values, err := buildQuery(pm.dialect(), QueryTypeDelete, []SQLField{},
+		SQLTable{TableName: pm.TableName}, []SQLJoin{}, f, "", "", 0, 0)
+	if err != nil {
+		return WriteResult{}, err
+	}
+
+	res, err := executeWithContext(ctx, pm, q, values)
+	res.LastInsertId = 0
+	return res, err
+}
+
+// Sync makes foreignIds the only links of the record id, missing links are attached and the others detached
+// in one transaction (a savepoint when the model is bound to a transaction)
+func (m *Model) Sync(foreignTable string, id interface{}, foreignIds ...interface{}) (WriteResult, error) {
+	return m.SyncContext(context.Background(), foreignTable, id, foreignIds...)
+}
+
+// SyncContext is Sync with a context
+func (m *Model) SyncContext(ctx context.Context, foreignTable string, id interface{}, foreignIds ...interface{}) (WriteResult, error) {
+	if m == nil {
+		return WriteResult{}, errors.New("cannot perform action: Sync() on nil model")
+	}
+
+	rel, err := m.manyToManyRelation(foreignTable)
+	if err != nil {
+		return WriteResult{}, err
+	}
+
+	var res WriteResult
+	err = m.inTx(ctx, func(tm *Model) error {
+		attached, err := tm.attachedKeys(ctx, rel.Join.Pivot, id)
+		if err != nil {
+			return err
+		}
+
+		keep := make(map[string]bool)
+		for _, fid := range foreignIds {
+			keep[relationKey(fid)] = true
+		}
+
+		detach := make([]interface{}, 0)
+		for k, fid := range attached {
+			if !keep[k] {
+				detach = append(detach, fid)
+			}
+		}
+		if len(detach) > 0 {
+			r, err := tm.DetachContext(ctx, foreignTable, id, detach...)
+			if err != nil {
+				return err
+			}
+			res.RowsAffected += r.RowsAffected
+		}
+
+		r, err := tm.AttachContext(ctx, foreignTable, id, foreignIds...)
+		if err != nil {
+			return err
+		}
+		res.RowsAffected += r.RowsAffected
+		return nil
+	})
+
+	return res, err
+}
+
+// attachedKeys returns the foreign keys linked to the record id in the pivot table
+func (m *Model) attachedKeys(ctx context.Context, pivot SQLPivot, id interface{}) (map[string]interface{}, error) {
+	pm := m.pivotModel(pivot)
+	rows, err := pm.GetRecordsContext(ctx, []Filter{{Field: pivot.LocalKey, Operator: "=", Value: id}}, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	attached := make(map[string]interface{})
+	for _, k := range distinctKeys(rows, pivot.ForeignKey) {
+		attached[relationKey(k)] = k
+	}
+	return attached, nil
+}
+
+// distinctKeys returns the distinct non NULL values of field
+func distinctKeys(rows []ResultRow, field string) []interface{} {
+	keys := make([]interface{}, 0)
+	for _, rr := range rows {
+		idx := rr.GetFieldIndex(field)
+		if idx < 0 || idx >= len(rr.Values) || rr.Values[idx] == nil {
+			continue
+		}
+		keys = append(keys, rr.Values[idx])
+	}
+	return distinctValues(keys)
+}
+
+// distinctValues removes duplicate keys, keeping the first occurrence
+func distinctValues(values []interface{}) []interface{} {
+	seen := make(map[string]bool)
+	d := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		k := relationKey(v)
+		if !seen[k] {
+			seen[k] = true
+			d = append(d, v)
+		}
+	}
+	return d
+}
+
+// relationKey returns the map key of a key value, int32 / int64 / string values of the same key match
+func relationKey(v interface{}) string {
+	switch k := v.(type) {
+	case []byte:
+		return string(k)
+	case sql.RawBytes:
+		return string(k)
+	}
+	return fmt.Sprint(v)
+}
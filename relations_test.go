@@ -0,0 +1,271 @@
+package gomvc
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// relationDB returns a new SQLite database with the brands, cars and parts tables
+func relationDB(t *testing.T) *sql.DB {
+	t.Helper()
+	InitHelpers(&AppConfig{})
+
+	db, err := ConnectDatabaseSQLite(filepath.Join(t.TempDir(), "relations.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	for _, q := range []string{
+		`CREATE TABLE brands (id INTEGER PRIMARY KEY, name VARCHAR(50) NOT NULL)`,
+		`CREATE TABLE cars (id INTEGER PRIMARY KEY, brand_id INTEGER REFERENCES brands(id), name VARCHAR(50) NOT NULL)`,
+		`CREATE TABLE parts (id INTEGER PRIMARY KEY, car_id INTEGER NOT NULL REFERENCES cars(id), name VARCHAR(50) NOT NULL)`,
+		`INSERT INTO brands (id, name) VALUES (1, 'ford'), (2, 'bmw'), (3, 'fiat')`,
+		`INSERT INTO cars (id, brand_id, name) VALUES (1, 1, 'focus'), (2, 1, 'fiesta'), (3, 2, 'x5'), (4, NULL, 'kit')`,
+		`INSERT INTO parts (id, car_id, name) VALUES (1, 1, 'wheel'), (2, 1, 'door'), (3, 3, 'seat')`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db
+}
+
+// relationModel returns the initialized model of table
+func relationModel(t *testing.T, db *sql.DB, table string) *Model {
+	t.Helper()
+	m := &Model{}
+	if err := m.InitModel(db, table, "id"); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// subNames returns the names of the subresult rows
+func subNames(r ResultRow) []string {
+	names := make([]string, len(r.Subresult))
+	for i, sr := range r.Subresult {
+		names[i] = sr.String("name")
+	}
+	return names
+}
+
+func TestEagerLoadingSQLite(t *testing.T) {
+	db := relationDB(t)
+	parts := relationModel(t, db, "parts")
+
+	cars := relationModel(t, db, "cars")
+	cars.AddModelRelation(parts, SQLKeyPair{LocalKey: "id", ForeignKey: "car_id"}, ModelJoinLeft, ResultStyleSubresult)
+
+	rr, err := cars.NewQueryBuilder().OrderBy("id", "ASC").Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[int64][]string{1: {"wheel", "door"}, 2: {}, 3: {"seat"}, 4: {}}
+	if len(rr) != len(want) {
+		t.Fatalf("got %d cars, want %d", len(rr), len(want))
+	}
+	for _, r := range rr {
+		got := subNames(r)
+		if len(got) != len(want[r.Int("id")]) {
+			t.Errorf("car %d parts = %v, want %v", r.Int("id"), got, want[r.Int("id")])
+			continue
+		}
+		for i := range got {
+			if got[i] != want[r.Int("id")][i] || r.Subresult[i].TableName != "parts" {
+				t.Errorf("car %d parts = %v, want %v", r.Int("id"), got, want[r.Int("id")])
+			}
+		}
+	}
+
+	// nested: the parts of the cars are loaded with the cars of the brands
+	brands := relationModel(t, db, "brands")
+	brands.AddModelRelation(cars, SQLKeyPair{LocalKey: "id", ForeignKey: "brand_id"}, ModelJoinLeft, ResultStyleSubresult)
+	br, err := brands.GetRecords([]Filter{{Field: "id", Operator: "=", Value: 1}}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(br) != 1 || len(br[0].Subresult) != 2 || len(br[0].Subresult[0].Subresult) != 2 {
+		t.Fatalf("brand 1 = %+v, want 2 cars and 2 parts of focus", br)
+	}
+
+	// belongs-to and has-one keep at most one row, a NULL key loads nothing
+	owner := relationModel(t, db, "cars")
+	owner.AddBelongsToRelation(brands, SQLKeyPair{LocalKey: "brand_id", ForeignKey: "id"}, ModelJoinLeft, ResultStyleSubresult)
+	owner.AddHasOneRelation(parts, SQLKeyPair{LocalKey: "id", ForeignKey: "car_id"}, ModelJoinLeft, ResultStyleSubresult)
+	rr, err = owner.NewQueryBuilder().OrderBy("id", "ASC").Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := subNames(rr[0]); len(got) != 2 || got[0] != "ford" || got[1] != "wheel" {
+		t.Errorf("focus subresult = %v, want [ford wheel]", got)
+	}
+	if got := subNames(rr[3]); len(got) != 0 {
+		t.Errorf("kit subresult = %v, want none", got)
+	}
+}
+
+func TestEagerLoadingBatchesSQLite(t *testing.T) {
+	db := relationDB(t)
+	_, err := db.Exec(`WITH RECURSIVE n(i) AS (SELECT 10 UNION ALL SELECT i + 1 FROM n WHERE i < 1210)
+		INSERT INTO parts (id, car_id, name) SELECT i, i, 'part' FROM n`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.Exec(`WITH RECURSIVE n(i) AS (SELECT 10 UNION ALL SELECT i + 1 FROM n WHERE i < 1210)
+		INSERT INTO cars (id, brand_id, name) SELECT i, 3, 'car' FROM n`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cars := relationModel(t, db, "cars")
+	cars.AddModelRelation(relationModel(t, db, "parts"), SQLKeyPair{LocalKey: "id", ForeignKey: "car_id"}, ModelJoinLeft, ResultStyleSubresult)
+	rr, err := cars.GetRecords([]Filter{{Field: "brand_id", Operator: "=", Value: 3}}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rr) != 1201 {
+		t.Fatalf("got %d cars, want 1201", len(rr))
+	}
+	for _, r := range rr {
+		if len(r.Subresult) != 1 || r.Subresult[0].Int("car_id") != r.Int("id") {
+			t.Fatalf("car %d subresult = %+v, want its part", r.Int("id"), r.Subresult)
+		}
+	}
+}
+
+// part and carParts are the structs of the parts and cars tables
+type part struct {
+	ID   int64  `db:"id"`
+	Name string `db:"name"`
+}
+
+type carParts struct {
+	ID    int64  `db:"id"`
+	Name  string `db:"name"`
+	Parts []part `db:"parts"`
+}
+
+func TestScanRelationSQLite(t *testing.T) {
+	db := relationDB(t)
+	cars := relationModel(t, db, "cars")
+	cars.AddModelRelation(relationModel(t, db, "parts"), SQLKeyPair{LocalKey: "id", ForeignKey: "car_id"}, ModelJoinLeft, ResultStyleSubresult)
+
+	var got []carParts
+	if err := cars.NewQueryBuilder().OrderBy("id", "ASC").ScanInto(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4 || len(got[0].Parts) != 2 || got[0].Parts[1].Name != "door" || len(got[1].Parts) != 0 {
+		t.Errorf("ScanInto = %+v", got)
+	}
+}
+
+// tagModels adds the tags and car_tags tables and returns the cars model with its many-to-many relation to tags,
+// tag ids of 100 and more fail the pivot CHECK constraint
+func tagModels(t *testing.T, db *sql.DB) *Model {
+	t.Helper()
+	for _, q := range []string{
+		`CREATE TABLE tags (id INTEGER PRIMARY KEY, name VARCHAR(50) NOT NULL)`,
+		`CREATE TABLE car_tags (car_id INTEGER NOT NULL, tag_id INTEGER NOT NULL CHECK (tag_id < 100), PRIMARY KEY (car_id, tag_id))`,
+		`INSERT INTO tags (id, name) VALUES (1, 'fast'), (2, 'red'), (3, 'old'), (100, 'invalid')`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cars := relationModel(t, db, "cars")
+	cars.AddManyToManyRelation(relationModel(t, db, "tags"), SQLPivot{Table: "car_tags", LocalKey: "car_id", ForeignKey: "tag_id"},
+		ModelJoinLeft, ResultStyleSubresult)
+	return cars
+}
+
+// carTags returns the tag names of the car loaded through the many-to-many relation
+func carTags(t *testing.T, cars *Model, id int64) []string {
+	t.Helper()
+	rr, err := cars.GetRecords([]Filter{{Field: "id", Operator: "=", Value: id}}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rr) != 1 {
+		t.Fatalf("got %d cars with id %d, want 1", len(rr), id)
+	}
+	return subNames(rr[0])
+}
+
+func TestManyToManySQLite(t *testing.T) {
+	cars := tagModels(t, relationDB(t))
+
+	res, err := cars.Attach("tags", 1, 1, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.RowsAffected != 2 {
+		t.Errorf("Attach RowsAffected = %d, want 2", res.RowsAffected)
+	}
+	// existing links are skipped
+	if res, err = cars.Attach("tags", 1, int64(2), "3"); err != nil || res.RowsAffected != 1 {
+		t.Errorf("Attach of a linked id = %+v, %v, want 1 row", res, err)
+	}
+	if got := carTags(t, cars, 1); len(got) != 3 || got[0] != "fast" || got[2] != "old" {
+		t.Errorf("tags of car 1 = %v, want [fast red old]", got)
+	}
+
+	if _, err := cars.Detach("tags", 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := carTags(t, cars, 1); len(got) != 2 || got[1] != "old" {
+		t.Errorf("tags after Detach = %v, want [fast old]", got)
+	}
+
+	if _, err := cars.Sync("tags", 1, 2, 3); err != nil {
+		t.Fatal(err)
+	}
+	if got := carTags(t, cars, 1); len(got) != 2 || got[0] != "red" || got[1] != "old" {
+		t.Errorf("tags after Sync = %v, want [red old]", got)
+	}
+
+	if _, err := cars.Detach("tags", 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := carTags(t, cars, 1); len(got) != 0 {
+		t.Errorf("tags after Detach of all = %v, want none", got)
+	}
+
+	if _, err := cars.Attach("brands", 1, 1); err == nil {
+		t.Error("Attach without a many-to-many relation succeeded")
+	}
+}
+
+func TestAttachRollsBackSQLite(t *testing.T) {
+	db := relationDB(t)
+	cars := tagModels(t, db)
+
+	// tag 100 fails after 1 and 2 are inserted
+	if _, err := cars.Attach("tags", 1, 1, 2, 100, 3); err == nil {
+		t.Fatal("Attach of an invalid id succeeded")
+	}
+	if got := carTags(t, cars, 1); len(got) != 0 {
+		t.Errorf("tags after failed Attach = %v, want none", got)
+	}
+
+	// bound to a transaction only the savepoint is rolled back
+	err := WithTx(context.Background(), db, func(tx *Tx) error {
+		tm := cars.WithTx(tx)
+		if _, err := tm.Attach("tags", 2, 1); err != nil {
+			return err
+		}
+		if _, err := tm.Attach("tags", 2, 2, 100); err == nil {
+			t.Error("Attach of an invalid id in a transaction succeeded")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := carTags(t, cars, 2); len(got) != 1 || got[0] != "fast" {
+		t.Errorf("tags of car 2 = %v, want [fast]", got)
+	}
+}
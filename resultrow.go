@@ -0,0 +1,110 @@
+package gomvc
+
+import (
+	"database/sql"
+	"reflect"
+	"time"
+)
+
+// Typed accessors of a result row, they can be used in templates: {{.String "name"}}.
+// NULL values and unknown fields return the zero value, the Null... accessors return Valid false,
+// values that cannot be converted to the requested type return the zero value too.
+
+// Value returns the value of a field, ok is false when the row has no such field
+func (r ResultRow) Value(name string) (interface{}, bool) {
+	i := r.GetFieldIndex(name)
+	if i < 0 || i >= len(r.Values) {
+		return nil, false
+	}
+	return r.Values[i], true
+}
+
+// IsNull reports whether a field is NULL or unknown
+func (r ResultRow) IsNull(name string) bool {
+	v, _ := r.Value(name)
+	return v == nil
+}
+
+// String returns a field as string, "" for NULL
+func (r ResultRow) String(name string) string {
+	return r.NullString(name).String
+}
+
+// NullString returns a field as sql.NullString
+func (r ResultRow) NullString(name string) sql.NullString {
+	v, ok := r.Value(name)
+	if !ok || v == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: valueText(v), Valid: true}
+}
+
+// Int returns a field as int64, 0 for NULL
+func (r ResultRow) Int(name string) int64 {
+	return r.NullInt(name).Int64
+}
+
+// NullInt returns a field as sql.NullInt64
+func (r ResultRow) NullInt(name string) sql.NullInt64 {
+	var n sql.NullInt64
+	n.Valid = r.convert(name, &n.Int64)
+	return n
+}
+
+// Float returns a field as float64, 0 for NULL
+func (r ResultRow) Float(name string) float64 {
+	return r.NullFloat(name).Float64
+}
+
+// NullFloat returns a field as sql.NullFloat64
+func (r ResultRow) NullFloat(name string) sql.NullFloat64 {
+	var n sql.NullFloat64
+	n.Valid = r.convert(name, &n.Float64)
+	return n
+}
+
+// Bool returns a field as bool, false for NULL
+func (r ResultRow) Bool(name string) bool {
+	return r.NullBool(name).Bool
+}
+
+// NullBool returns a field as sql.NullBool
+func (r ResultRow) NullBool(name string) sql.NullBool {
+	var n sql.NullBool
+	n.Valid = r.convert(name, &n.Bool)
+	return n
+}
+
+// Time returns a field as time.Time, the zero time for NULL
+func (r ResultRow) Time(name string) time.Time {
+	return r.NullTime(name).Time
+}
+
+// NullTime returns a field as sql.NullTime
+func (r ResultRow) NullTime(name string) sql.NullTime {
+	var n sql.NullTime
+	n.Valid = r.convert(name, &n.Time)
+	return n
+}
+
+// Decimal returns a field as Decimal, "" for NULL
+func (r ResultRow) Decimal(name string) Decimal {
+	var d Decimal
+	r.convert(name, &d)
+	return d
+}
+
+// convert assigns a field value to dest, false for NULL, unknown fields and conversion errors
+func (r ResultRow) convert(name string, dest interface{}) bool {
+	v, ok := r.Value(name)
+	if !ok || v == nil {
+		return false
+	}
+
+	dv := reflect.ValueOf(dest).Elem()
+	if err := setFieldValue(dv, v); err != nil {
+		dv.Set(reflect.Zero(dv.Type()))
+		return false
+	}
+	return true
+}
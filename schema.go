@@ -0,0 +1,154 @@
+package gomvc
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Column is the metadata of a table column, InitModel reads the columns of the model table into Model.Columns.
+// Templates can build forms from it: {{range .Model.Columns}}<input type="{{.InputType}}" name="{{.Name}}">{{end}}
+type Column struct {
+	Name          string
+	Type          string // upper case type name without length, e.g. VARCHAR
+	Length        int64  // max length of CHAR / VARCHAR columns, 0 for other types
+	Unsigned      bool
+	Nullable      bool
+	HasDefault    bool
+	Default       string // default value (unquoted) or expression, e.g. CURRENT_TIMESTAMP, when HasDefault is set
+	Key           string // PRI (primary key), UNI (unique), MUL (index or foreign key), empty for other columns
+	AutoIncrement bool
+	Enum          []string // values of ENUM columns
+}
+
+// ForeignKey is a foreign key constraint of a table, InitModel reads the foreign keys of the model table into Model.ForeignKeys
+type ForeignKey struct {
+	Name       string // constraint name, empty for SQLite
+	Table      string // table of the constraint columns
+	Columns    []string
+	RefTable   string
+	RefColumns []string
+}
+
+// Column returns the metadata of a model column, nil when the model has no such column
+func (m *Model) Column(name string) *Column {
+	for i := range m.Columns {
+		if m.Columns[i].Name == name {
+			return &m.Columns[i]
+		}
+	}
+	return nil
+}
+
+// ForeignKey returns the single column foreign key of a model column, nil when the column has none
+func (m *Model) ForeignKey(column string) *ForeignKey {
+	for i, fk := range m.ForeignKeys {
+		if len(fk.Columns) == 1 && fk.Columns[0] == column {
+			return &m.ForeignKeys[i]
+		}
+	}
+	return nil
+}
+
+// IsRequired reports whether an insert must set the column: NOT NULL without default and not auto increment
+func (c Column) IsRequired() bool {
+	return !c.Nullable && !c.HasDefault && !c.AutoIncrement
+}
+
+// InputType returns the HTML input type for the column: select (ENUM), checkbox, number, date, datetime-local,
+// time, textarea (TEXT types) or text
+func (c Column) InputType() string {
+	if len(c.Enum) > 0 {
+		return "select"
+	}
+	switch c.Type {
+	case "BOOL", "BOOLEAN":
+		return "checkbox"
+	case "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "BIGINT", "INT2", "INT4", "INT8",
+		"DECIMAL", "NUMERIC", "DEC", "FIXED", "FLOAT", "FLOAT4", "FLOAT8", "REAL", "DOUBLE", "DOUBLE PRECISION", "YEAR":
+		return "number"
+	case "DATE":
+		return "date"
+	case "DATETIME", "TIMESTAMP", "TIMESTAMPTZ":
+		return "datetime-local"
+	case "TIME":
+		return "time"
+	case "TINYTEXT", "TEXT", "MEDIUMTEXT", "LONGTEXT", "CLOB":
+		return "textarea"
+	}
+	return "text"
+}
+
+// parseColumnType sets the type, length, unsigned flag and enum values of c from a declared column type,
+// e.g. varchar(50) -> VARCHAR, 50 and int(11) unsigned -> INT, unsigned (ZEROFILL is removed)
+func parseColumnType(c *Column, t string) {
+	t = strings.TrimSpace(t)
+
+	args, rest := "", ""
+	if i := strings.Index(t, "("); i > -1 {
+		if j := strings.LastIndex(t, ")"); j > i {
+			args, rest = t[i+1:j], t[j+1:]
+		}
+		t = t[:i] + " " + rest
+	}
+	c.Type, c.Unsigned = baseTypeName(strings.ToUpper(t))
+
+	c.Length, c.Enum = 0, nil
+	switch c.Type {
+	case "CHAR", "VARCHAR", "CHARACTER", "CHARACTER VARYING", "NCHAR", "NVARCHAR", "VARYING CHARACTER", "NATIVE CHARACTER":
+		c.Length, _ = strconv.ParseInt(strings.TrimSpace(args), 10, 64)
+	case "ENUM":
+		c.Enum = parseQuotedList(args)
+	}
+}
+
+// parseQuotedList returns the values of a list of SQL strings, e.g. 'a','b' -> a, b (doubled quotes are unescaped)
+func parseQuotedList(s string) []string {
+	var out []string
+	var sb strings.Builder
+	in := false
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case !in && ch == '\'':
+			in = true
+			sb.Reset()
+		case in && ch == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			sb.WriteByte('\'')
+			i++
+		case in && ch == '\'':
+			in = false
+			out = append(out, sb.String())
+		case in:
+			sb.WriteByte(ch)
+		}
+	}
+	return out
+}
+
+// pgCastPattern matches the type cast of a PostgreSQL default, e.g. 'a'::character varying
+var pgCastPattern = regexp.MustCompile(`::[A-Za-z_][A-Za-z0-9_ ]*(\[\])?$`)
+
+// columnDefault normalizes a column default: casts are removed and quoted strings unquoted
+func columnDefault(d string) string {
+	d = strings.TrimSpace(d)
+	for pgCastPattern.MatchString(d) {
+		d = strings.TrimSpace(pgCastPattern.ReplaceAllString(d, ""))
+	}
+	if len(d) >= 2 && d[0] == '\'' && d[len(d)-1] == '\'' {
+		if v := parseQuotedList(d); len(v) == 1 {
+			return v[0]
+		}
+	}
+	return d
+}
+
+// setColumnKey sets the key of a column unless it has a stronger key (PRI > UNI > MUL)
+func setColumnKey(cols []Column, name, key string) {
+	rank := map[string]int{"": 0, "MUL": 1, "UNI": 2, "PRI": 3}
+	for i := range cols {
+		if cols[i].Name == name && rank[key] > rank[cols[i].Key] {
+			cols[i].Key = key
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package gomvc
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestParseColumnType(t *testing.T) {
+	tests := []struct {
+		t    string
+		want Column
+	}{
+		{"varchar(50)", Column{Type: "VARCHAR", Length: 50}},
+		{"int(11) unsigned zerofill", Column{Type: "INT", Unsigned: true}},
+		{"decimal(10,2)", Column{Type: "DECIMAL"}},
+		{"character varying(20)", Column{Type: "CHARACTER VARYING", Length: 20}},
+		{"enum('a','it''s')", Column{Type: "ENUM", Enum: []string{"a", "it's"}}},
+		{"TEXT", Column{Type: "TEXT"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.t, func(t *testing.T) {
+			var c Column
+			parseColumnType(&c, tt.t)
+			if !reflect.DeepEqual(c, tt.want) {
+				t.Errorf("parseColumnType(%q) = %+v, want %+v", tt.t, c, tt.want)
+			}
+		})
+	}
+}
+
+func TestColumnDefault(t *testing.T) {
+	tests := []struct {
+		d    string
+		want string
+	}{
+		{"'new'", "new"},
+		{"'it''s'", "it's"},
+		{"'a'::character varying", "a"},
+		{"'{}'::text[]", "{}"},
+		{"CURRENT_TIMESTAMP", "CURRENT_TIMESTAMP"},
+		{" 0 ", "0"},
+		{"nextval('items_id_seq'::regclass)", "nextval('items_id_seq'::regclass)"},
+	}
+
+	for _, tt := range tests {
+		if got := columnDefault(tt.d); got != tt.want {
+			t.Errorf("columnDefault(%q) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestColumnsSQLite(t *testing.T) {
+	db := relationDB(t)
+	for _, q := range []string{
+		`CREATE TABLE items (id INTEGER PRIMARY KEY AUTOINCREMENT, code VARCHAR(10) NOT NULL UNIQUE, status TEXT DEFAULT 'new',
+			brand_id INTEGER REFERENCES brands(id), qty INT UNSIGNED NOT NULL DEFAULT 0, created DATETIME DEFAULT CURRENT_TIMESTAMP)`,
+		`CREATE INDEX items_qty ON items (qty)`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := relationModel(t, db, "items")
+
+	want := []Column{
+		{Name: "id", Type: "INTEGER", HasDefault: true, Key: "PRI", AutoIncrement: true},
+		{Name: "code", Type: "VARCHAR", Length: 10, Key: "UNI"},
+		{Name: "status", Type: "TEXT", Nullable: true, HasDefault: true, Default: "new"},
+		{Name: "brand_id", Type: "INTEGER", Nullable: true, Key: "MUL"},
+		{Name: "qty", Type: "INT", Unsigned: true, HasDefault: true, Default: "0", Key: "MUL"},
+		{Name: "created", Type: "DATETIME", Nullable: true, HasDefault: true, Default: "CURRENT_TIMESTAMP"},
+	}
+	if !reflect.DeepEqual(m.Columns, want) {
+		t.Errorf("Columns = %+v\nwant %+v", m.Columns, want)
+	}
+	if c := m.Column("code"); c == nil || !c.IsRequired() || c.InputType() != "text" {
+		t.Errorf("Column(code) = %+v, want a required text column", c)
+	}
+	if c := m.Column("id"); c.IsRequired() || c.InputType() != "number" {
+		t.Errorf("Column(id) = %+v, want an optional number column", c)
+	}
+	if m.Column("nope") != nil {
+		t.Error("Column of an unknown column is not nil")
+	}
+
+	fk := m.ForeignKey("brand_id")
+	if fk == nil || fk.RefTable != "brands" || !reflect.DeepEqual(fk.RefColumns, []string{"id"}) || fk.Table != "items" {
+		t.Errorf("ForeignKey(brand_id) = %+v, want items.brand_id -> brands.id", fk)
+	}
+	if m.ForeignKey("qty") != nil {
+		t.Error("ForeignKey of a column without foreign key is not nil")
+	}
+
+	refs, err := m.dialect().ReferencedBy(context.Background(), db, "brands")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tables := make([]string, len(refs))
+	for i, r := range refs {
+		tables[i] = r.Table
+	}
+	if !reflect.DeepEqual(tables, []string{"cars", "items"}) {
+		t.Errorf("tables referencing brands = %v, want [cars items]", tables)
+	}
+}
@@ -0,0 +1,307 @@
+package gomvc
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Identifiers (table, column, alias names) are validated and quoted with the dialect quoting before they are
+// written to a query, values are always sent as bind parameters. Allowed identifiers are
+// letters, digits, _ and $, not starting with a digit, optionally qualified with a table name (table.column).
+
+var (
+	// ErrInvalidIdentifier is returned when a table / column name is not a valid identifier or not a model column
+	ErrInvalidIdentifier = errors.New("invalid identifier")
+	// ErrInvalidOperator is returned for operators, logic and sort directions that are not whitelisted
+	ErrInvalidOperator = errors.New("invalid operator")
+)
+
+var (
+	identPattern     = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*$`)
+	aliasPattern     = regexp.MustCompile(`(?i)^(.+?)(?:\s+AS)?\s+([A-Za-z_][A-Za-z0-9_$]*)$`)
+	aggregatePattern = regexp.MustCompile(`(?i)^(COUNT|SUM|AVG|MIN|MAX)\s*\(\s*(DISTINCT\s+)?(.+?)\s*\)$`)
+)
+
+// operators is the operator whitelist
+var operators = map[string]bool{
+	"=": true, "<>": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true,
+	"LIKE": true, "NOT LIKE": true,
+	"IN": true, "NOT IN": true,
+	"BETWEEN": true, "NOT BETWEEN": true,
+	"IS NULL": true, "IS NOT NULL": true,
+	"EXISTS": true, "NOT EXISTS": true,
+}
+
+// orderTerm is a column of an ORDER BY clause
+type orderTerm struct {
+	column    string
+	direction string
+}
+
+// validIdent checks a table or column name, qualified names (table.column) and * (table.*) when allowStar is set
+func validIdent(name string, allowStar bool) bool {
+	parts := strings.Split(name, ".")
+	if len(parts) > 2 {
+		return false
+	}
+	for i, p := range parts {
+		if p == "*" && allowStar && i == len(parts)-1 {
+			continue
+		}
+		if !identPattern.MatchString(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// quoteColumn validates and quotes a column name
+func quoteColumn(d Dialect, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if !validIdent(name, false) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidIdentifier, name)
+	}
+	return d.QuoteIdent(name), nil
+}
+
+// quoteTable validates and quotes a table name
+func quoteTable(d Dialect, name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if !identPattern.MatchString(name) {
+		return "", fmt.Errorf("%w: table %q", ErrInvalidIdentifier, name)
+	}
+	return d.QuoteIdent(name), nil
+}
+
+// quoteSelectExpr validates and quotes a select expression: *, column, table.*, table.column,
+// COUNT / SUM / AVG / MIN / MAX([DISTINCT] column) with an optional [AS] alias.
+// The column used by the expression is returned ("" for * and COUNT(*)).
+func quoteSelectExpr(d Dialect, expr string) (string, string, error) {
+	expr = strings.TrimSpace(expr)
+
+	alias := ""
+	if m := aliasPattern.FindStringSubmatch(expr); m != nil && !strings.EqualFold(strings.TrimSpace(m[1]), "DISTINCT") {
+		expr, alias = strings.TrimSpace(m[1]), " AS "+d.QuoteIdent(m[2])
+	}
+
+	if m := aggregatePattern.FindStringSubmatch(expr); m != nil {
+		fn := strings.ToUpper(m[1])
+		distinct := ""
+		if len(m[2]) > 0 {
+			distinct = "DISTINCT "
+		}
+		if m[3] == "*" {
+			if fn != "COUNT" || len(distinct) > 0 {
+				return "", "", fmt.Errorf("%w: %q", ErrInvalidIdentifier, expr)
+			}
+			return fn + "(*)" + alias, "", nil
+		}
+		col, err := quoteColumn(d, m[3])
+		if err != nil {
+			return "", "", err
+		}
+		return fn + "(" + distinct + col + ")" + alias, m[3], nil
+	}
+
+	if !validIdent(expr, true) {
+		return "", "", fmt.Errorf("%w: %q", ErrInvalidIdentifier, expr)
+	}
+	if expr == "*" || strings.HasSuffix(expr, ".*") {
+		return d.QuoteIdent(expr) + alias, "", nil
+	}
+	return d.QuoteIdent(expr) + alias, expr, nil
+}
+
+// normalizeOperator returns the upper case operator with single spaces, error if it is not whitelisted
+func normalizeOperator(op string) (string, error) {
+	n := strings.ToUpper(strings.Join(strings.Fields(op), " "))
+	if !operators[n] {
+		return "", fmt.Errorf("%w: %q", ErrInvalidOperator, op)
+	}
+	return n, nil
+}
+
+// normalizeLogic returns AND / OR, empty logic is AND
+func normalizeLogic(logic string) (string, error) {
+	switch strings.ToUpper(strings.TrimSpace(logic)) {
+	case "", "AND":
+		return "AND", nil
+	case "OR":
+		return "OR", nil
+	}
+	return "", fmt.Errorf("%w: logic %q", ErrInvalidOperator, logic)
+}
+
+// normalizeDirection returns ASC / DESC, empty direction is ASC
+func normalizeDirection(direction string) (string, error) {
+	switch strings.ToUpper(strings.TrimSpace(direction)) {
+	case "", "ASC":
+		return "ASC", nil
+	case "DESC":
+		return "DESC", nil
+	}
+	return "", fmt.Errorf("%w: order direction %q", ErrInvalidOperator, direction)
+}
+
+// parseGroupBy parses "[GROUP BY] col1, col2"
+func parseGroupBy(group string) ([]string, error) {
+	group = trimKeyword(group, "GROUP BY")
+	if len(group) == 0 {
+		return nil, nil
+	}
+
+	cols := strings.Split(group, ",")
+	for i, c := range cols {
+		cols[i] = strings.TrimSpace(c)
+		if !validIdent(cols[i], false) {
+			return nil, fmt.Errorf("%w: group by %q", ErrInvalidIdentifier, cols[i])
+		}
+	}
+	return cols, nil
+}
+
+// parseOrderBy parses "[ORDER BY] col1 [ASC|DESC], col2 [ASC|DESC]"
+func parseOrderBy(order string) ([]orderTerm, error) {
+	order = trimKeyword(order, "ORDER BY")
+	if len(order) == 0 {
+		return nil, nil
+	}
+
+	terms := make([]orderTerm, 0)
+	for _, t := range strings.Split(order, ",") {
+		parts := strings.Fields(t)
+		if len(parts) == 0 || len(parts) > 2 || !validIdent(parts[0], false) {
+			return nil, fmt.Errorf("%w: order by %q", ErrInvalidIdentifier, strings.TrimSpace(t))
+		}
+		dir := ""
+		if len(parts) == 2 {
+			dir = parts[1]
+		}
+		dir, err := normalizeDirection(dir)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, orderTerm{column: parts[0], direction: dir})
+	}
+	return terms, nil
+}
+
+// trimKeyword removes a leading keyword (case insensitive) and spaces
+func trimKeyword(s string, keyword string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= len(keyword) && strings.EqualFold(s[:len(keyword)], keyword) {
+		s = strings.TrimSpace(s[len(keyword):])
+	}
+	return s
+}
+
+// listValues converts the value of an IN / BETWEEN filter to a list, any slice type is accepted
+func listValues(v interface{}) []interface{} {
+	switch l := v.(type) {
+	case []interface{}:
+		return l
+	case nil:
+		return []interface{}{}
+	case []byte:
+		return []interface{}{l}
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		l := make([]interface{}, rv.Len())
+		for i := range l {
+			l[i] = rv.Index(i).Interface()
+		}
+		return l
+	}
+	return []interface{}{v}
+}
+
+// checkColumn verifies that a column is known by the model, models without introspected Fields are not checked.
+// Columns of other tables (joins) are accepted when the model has no fields of that table.
+func (m *Model) checkColumn(name string) error {
+	if m == nil || len(m.Fields) == 0 || len(name) == 0 {
+		return nil
+	}
+
+	if FindInSlice(m.Fields, name) > -1 {
+		return nil
+	}
+
+	if i := strings.Index(name, "."); i > -1 {
+		table, col := name[:i], name[i+1:]
+		if table == m.TableName {
+			if col == "*" || FindInSlice(m.Fields, col) > -1 {
+				return nil
+			}
+		} else {
+			known := false
+			for _, f := range m.Fields {
+				if strings.HasPrefix(f, table+".") {
+					known = true
+					break
+				}
+			}
+			if !known || col == "*" {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("%w: unknown column %q in table %q", ErrInvalidIdentifier, name, m.TableName)
+}
+
+// checkFilters verifies the filter columns against the model columns, raw and subquery filters are not checked
+func (m *Model) checkFilters(filters []Filter) error {
+	for _, f := range filters {
+		if len(f.Group) > 0 {
+			if err := m.checkFilters(f.Group); err != nil {
+				return err
+			}
+			continue
+		}
+		if len(f.Raw) > 0 {
+			continue
+		}
+		if _, ok := f.Value.(*QueryBuilder); ok {
+			continue
+		}
+		if err := m.checkColumn(strings.TrimSpace(f.Field)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// countPlaceholders counts the ? placeholders outside quoted strings
+func countPlaceholders(q string) int {
+	n := 0
+	var quote byte
+	for i := 0; i < len(q); i++ {
+		ch := q[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"' || ch == '`':
+			quote = ch
+		case ch == '?':
+			n++
+		}
+	}
+	return n
+}
+
+// checkFields verifies the field names against the model columns
+func (m *Model) checkFields(fields []SQLField) error {
+	for _, f := range fields {
+		if err := m.checkColumn(strings.TrimSpace(f.FieldName)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
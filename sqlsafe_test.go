@@ -0,0 +1,71 @@
+package gomvc
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseOrderBy(t *testing.T) {
+	tests := []struct {
+		order string
+		want  []orderTerm
+		err   error
+	}{
+		{"", nil, nil},
+		{"name", []orderTerm{{"name", "ASC"}}, nil},
+		{"ORDER BY price desc, name", []orderTerm{{"price", "DESC"}, {"name", "ASC"}}, nil},
+		{"order by cars.name ASC", []orderTerm{{"cars.name", "ASC"}}, nil},
+		{"name sideways", nil, ErrInvalidOperator},
+		{"name; DROP TABLE cars", nil, ErrInvalidIdentifier},
+		{"name desc extra", nil, ErrInvalidIdentifier},
+		{"a.b.c", nil, ErrInvalidIdentifier},
+		{"name,", nil, ErrInvalidIdentifier},
+	}
+
+	for _, tt := range tests {
+		got, err := parseOrderBy(tt.order)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("parseOrderBy(%q) error = %v, want %v", tt.order, err, tt.err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseOrderBy(%q) = %v, want %v", tt.order, got, tt.want)
+		}
+	}
+}
+
+func TestQuoteSelectExpr(t *testing.T) {
+	tests := []struct {
+		d      Dialect
+		expr   string
+		want   string
+		column string
+		err    error
+	}{
+		{MySQLDialect{}, "*", "*", "", nil},
+		{MySQLDialect{}, "name", "`name`", "name", nil},
+		{MySQLDialect{}, "cars.*", "`cars`.*", "", nil},
+		{MySQLDialect{}, "cars.name AS car", "`cars`.`name` AS `car`", "cars.name", nil},
+		{PostgresDialect{}, "cars.name car", `"cars"."name" AS "car"`, "cars.name", nil},
+		{PostgresDialect{}, "count(*) as n", `COUNT(*) AS "n"`, "", nil},
+		{SQLiteDialect{}, "SUM(DISTINCT price) total", `SUM(DISTINCT "price") AS "total"`, "price", nil},
+		{SQLiteDialect{}, "max( price )", `MAX("price")`, "price", nil},
+		{MySQLDialect{}, "SUM(*)", "", "", ErrInvalidIdentifier},
+		{MySQLDialect{}, "COUNT(DISTINCT *)", "", "", ErrInvalidIdentifier},
+		{MySQLDialect{}, "name; DROP TABLE cars", "", "", ErrInvalidIdentifier},
+		{MySQLDialect{}, "SLEEP(5)", "", "", ErrInvalidIdentifier},
+		{MySQLDialect{}, "1name", "", "", ErrInvalidIdentifier},
+	}
+
+	for _, tt := range tests {
+		got, column, err := quoteSelectExpr(tt.d, tt.expr)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("quoteSelectExpr(%s, %q) error = %v, want %v", tt.d.Name(), tt.expr, err, tt.err)
+			continue
+		}
+		if got != tt.want || column != tt.column {
+			t.Errorf("quoteSelectExpr(%s, %q) = %q, %q, want %q, %q", tt.d.Name(), tt.expr, got, column, tt.want, tt.column)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package gomvc
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// trashedScope selects the soft deleted rows returned by queries of models with SoftDeleteField
+type trashedScope int
+
+const (
+	withoutTrashed trashedScope = iota
+	withTrashed
+	onlyTrashed
+)
+
+// timeNow returns the time written to the timestamp and soft delete columns
+var timeNow = time.Now
+
+// withTimestamps adds the CreatedAtField (insert only) and UpdatedAtField columns with the current time,
+// listed fields keep their value unless it is a zero time.Time. On update a zero CreatedAtField is removed.
+func (m *Model) withTimestamps(fields []SQLField, insert bool) []SQLField {
+	if len(m.CreatedAtField) == 0 && len(m.UpdatedAtField) == 0 {
+		return fields
+	}
+
+	now := timeNow()
+	out := make([]SQLField, 0, len(fields)+2)
+	hasCreated, hasUpdated := false, false
+	for _, f := range fields {
+		zero := false
+		if t, ok := f.Value.(time.Time); ok && t.IsZero() {
+			zero = true
+		}
+
+		switch {
+		case len(m.CreatedAtField) > 0 && f.FieldName == m.CreatedAtField:
+			if zero && !insert {
+				continue
+			}
+			if zero {
+				f.Value = now
+			}
+			hasCreated = true
+		case len(m.UpdatedAtField) > 0 && f.FieldName == m.UpdatedAtField:
+			if zero {
+				f.Value = now
+			}
+			hasUpdated = true
+		}
+		out = append(out, f)
+	}
+
+	if insert && len(m.CreatedAtField) > 0 && !hasCreated {
+		out = append(out, SQLField{FieldName: m.CreatedAtField, Value: now})
+	}
+	if len(m.UpdatedAtField) > 0 && !hasUpdated {
+		out = append(out, SQLField{FieldName: m.UpdatedAtField, Value: now})
+	}
+
+	return out
+}
+
+// WithTrashed returns a copy of the model whose queries include soft deleted rows
+func (m *Model) WithTrashed() *Model {
+	tm := *m
+	tm.trashed = withTrashed
+	return &tm
+}
+
+// OnlyTrashed returns a copy of the model whose queries return only soft deleted rows
+func (m *Model) OnlyTrashed() *Model {
+	tm := *m
+	tm.trashed = onlyTrashed
+	return &tm
+}
+
+// WithTrashed includes soft deleted rows
+func (qb *QueryBuilder) WithTrashed() *QueryBuilder {
+	qb.trashed = withTrashed
+	return qb
+}
+
+// OnlyTrashed returns only soft deleted rows
+func (qb *QueryBuilder) OnlyTrashed() *QueryBuilder {
+	qb.trashed = onlyTrashed
+	return qb
+}
+
+// scopeFilters adds the soft delete condition to filters, the filters are grouped to keep OR conditions together
+func (m *Model) scopeFilters(filters []Filter, scope trashedScope) []Filter {
+	if len(m.SoftDeleteField) == 0 || scope == withTrashed {
+		return filters
+	}
+
+	f := Filter{Field: m.TableName + "." + m.SoftDeleteField, Operator: "IS NULL"}
+	if scope == onlyTrashed {
+		f.Operator = "IS NOT NULL"
+	}
+
+	if len(filters) == 0 {
+		return []Filter{f}
+	}
+	f.Logic = "AND"
+	return []Filter{{Group: filters}, f}
+}
+
+// Restore clears the SoftDeleteField of a soft deleted record
+func (m *Model) Restore(id string) (WriteResult, error) {
+	return m.RestoreContext(context.Background(), id)
+}
+
+// RestoreContext is Restore with a context
+func (m *Model) RestoreContext(ctx context.Context, id string) (WriteResult, error) {
+	if m == nil {
+		return WriteResult{}, errors.New("cannot perform action: Restore() on nil model")
+	}
+
+	key, err := m.idKey(id)
+	if err != nil {
+		return WriteResult{}, err
+	}
+	return m.RestoreByKeyContext(ctx, key)
+}
+
+// RestoreByKey is Restore for the record of key, it works with composite primary keys (PKFields)
+func (m *Model) RestoreByKey(key Key) (WriteResult, error) {
+	return m.RestoreByKeyContext(context.Background(), key)
+}
+
+// RestoreByKeyContext is RestoreByKey with a context
+func (m *Model) RestoreByKeyContext(ctx context.Context, key Key) (WriteResult, error) {
+	if m == nil {
+		return WriteResult{}, errors.New("cannot perform action: RestoreByKey() on nil model")
+	}
+	if len(m.SoftDeleteField) == 0 {
+		return WriteResult{}, errors.New("restore needs a model with SoftDeleteField")
+	}
+
+	fields := []SQLField{{FieldName: m.SoftDeleteField, Value: nil}}
+	hc := &HookContext{Event: BeforeUpdate, Fields: fields, ID: m.keyText(key), Key: key}
+	return m.withHooks(ctx, hc, func(tm *Model, fields []SQLField) (WriteResult, error) {
+		res, err := tm.NewQueryBuilder().OnlyTrashed().whereKey(key).update(ctx, fields)
+		if err == nil && tm.RequireRowsAffected && res.RowsAffected == 0 {
+			return res, ErrRecordNotFound
+		}
+		return res, err
+	})
+}
+
+// ForceDelete deletes a record, also when the model has SoftDeleteField
+func (m *Model) ForceDelete(id string) (WriteResult, error) {
+	return m.ForceDeleteContext(context.Background(), id)
+}
+
+// ForceDeleteContext is ForceDelete with a context
+func (m *Model) ForceDeleteContext(ctx context.Context, id string) (WriteResult, error) {
+	if m == nil {
+		return WriteResult{}, errors.New("cannot perform action: ForceDelete() on nil model")
+	}
+
+	key, err := m.idKey(id)
+	if err != nil {
+		return WriteResult{}, err
+	}
+	return m.ForceDeleteByKeyContext(ctx, key)
+}
+
+// ForceDeleteByKey is ForceDelete for the record of key, it works with composite primary keys (PKFields)
+func (m *Model) ForceDeleteByKey(key Key) (WriteResult, error) {
+	return m.ForceDeleteByKeyContext(context.Background(), key)
+}
+
+// ForceDeleteByKeyContext is ForceDeleteByKey with a context
+func (m *Model) ForceDeleteByKeyContext(ctx context.Context, key Key) (WriteResult, error) {
+	if m == nil {
+		return WriteResult{}, errors.New("cannot perform action: ForceDeleteByKey() on nil model")
+	}
+
+	return m.withHooks(ctx, &HookContext{Event: BeforeDelete, ID: m.keyText(key), Key: key}, func(tm *Model, fields []SQLField) (WriteResult, error) {
+		res, err := tm.NewQueryBuilder().WithTrashed().whereKey(key).delete(ctx, true)
+		if err == nil && tm.RequireRowsAffected && res.RowsAffected == 0 {
+			return res, ErrRecordNotFound
+		}
+		return res, err
+	})
+}
+
+// ForceDelete deletes the matching rows, also when the model has SoftDeleteField
+func (qb *QueryBuilder) ForceDelete() (WriteResult, error) {
+	return qb.ForceDeleteContext(context.Background())
+}
+
+// ForceDeleteContext is ForceDelete with a context
+func (qb *QueryBuilder) ForceDeleteContext(ctx context.Context) (WriteResult, error) {
+	return qb.model.withHooks(ctx, &HookContext{Event: BeforeDelete}, func(tm *Model, fields []SQLField) (WriteResult, error) {
+		tqb := *qb
+		tqb.model = tm
+		return tqb.delete(ctx, true)
+	})
+}
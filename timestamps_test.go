@@ -0,0 +1,120 @@
+package gomvc
+
+import (
+	"testing"
+	"time"
+)
+
+// postsModel returns the model of a posts table with timestamps and soft deletes
+func postsModel(t *testing.T) *Model {
+	t.Helper()
+	m := testModel(t)
+	_, err := m.DB.Exec(`CREATE TABLE posts (id INTEGER PRIMARY KEY AUTOINCREMENT, title VARCHAR(50) NOT NULL,
+		created_at DATETIME, updated_at DATETIME, deleted_at DATETIME)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := &Model{CreatedAtField: "created_at", UpdatedAtField: "updated_at", SoftDeleteField: "deleted_at"}
+	if err := p.InitModel(m.DB, "posts", "id"); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// postTitles returns the titles of the posts ordered by id
+func postTitles(t *testing.T, qb *QueryBuilder) []string {
+	t.Helper()
+	rr, err := qb.OrderBy("id", "ASC").Execute()
+	if err != nil {
+		t.Fatal(err)
+	}
+	titles := make([]string, len(rr))
+	for i, r := range rr {
+		titles[i] = r.String("title")
+	}
+	return titles
+}
+
+func TestTimestampsSQLite(t *testing.T) {
+	p := postsModel(t)
+	defer func(now func() time.Time) { timeNow = now }(timeNow)
+	created := time.Date(2021, 5, 6, 7, 8, 9, 0, time.UTC)
+	timeNow = func() time.Time { return created }
+
+	if _, err := p.Insert([]SQLField{{FieldName: "title", Value: "first"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	updated := created.Add(time.Minute)
+	timeNow = func() time.Time { return updated }
+	// a zero created_at is not written by updates
+	if _, err := p.Update([]SQLField{{FieldName: "title", Value: "changed"}, {FieldName: "created_at", Value: time.Time{}}}, "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := p.FindByKey(Key{"id": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Time("created_at").Equal(created) || !r.Time("updated_at").Equal(updated) || !r.IsNull("deleted_at") {
+		t.Errorf("post = %v, want created %v, updated %v", r, created, updated)
+	}
+}
+
+func TestSoftDeleteSQLite(t *testing.T) {
+	p := postsModel(t)
+	for _, title := range []string{"a", "b", "c"} {
+		if _, err := p.Insert([]SQLField{{FieldName: "title", Value: title}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := p.Delete("1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.NewQueryBuilder().Where("title", "=", "b").Delete(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := postTitles(t, p.NewQueryBuilder()); len(got) != 1 || got[0] != "c" {
+		t.Errorf("posts = %v, want [c]", got)
+	}
+	// OR conditions don't bypass the soft delete scope
+	if got := postTitles(t, p.NewQueryBuilder().Where("title", "=", "a").OrWhere("title", "=", "c")); len(got) != 1 {
+		t.Errorf("posts a or c = %v, want [c]", got)
+	}
+	if got := postTitles(t, p.NewQueryBuilder().WithTrashed()); len(got) != 3 {
+		t.Errorf("posts with trashed = %v, want 3", got)
+	}
+	if got := postTitles(t, p.NewQueryBuilder().OnlyTrashed()); len(got) != 2 || got[1] != "b" {
+		t.Errorf("trashed posts = %v, want [a b]", got)
+	}
+	if rr, err := p.OnlyTrashed().GetRecords(nil, 0); err != nil || len(rr) != 2 {
+		t.Errorf("OnlyTrashed GetRecords = %d rows, %v, want 2", len(rr), err)
+	}
+
+	res, err := p.Restore("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.RowsAffected != 1 {
+		t.Errorf("Restore RowsAffected = %d, want 1", res.RowsAffected)
+	}
+	// only trashed records are restored
+	if res, err := p.Restore("3"); err != nil || res.RowsAffected != 0 {
+		t.Errorf("Restore of a record that is not deleted = %+v, %v, want no row", res, err)
+	}
+
+	if _, err := p.ForceDelete("2"); err != nil {
+		t.Fatal(err)
+	}
+	if got := postTitles(t, p.NewQueryBuilder().WithTrashed()); len(got) != 2 || got[0] != "a" || got[1] != "c" {
+		t.Errorf("posts after ForceDelete = %v, want [a c]", got)
+	}
+
+	p.RequireRowsAffected = true
+	if _, err := p.Restore("2"); err != ErrRecordNotFound {
+		t.Errorf("Restore of a deleted record error = %v, want %v", err, ErrRecordNotFound)
+	}
+}
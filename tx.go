@@ -0,0 +1,180 @@
+package gomvc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// dbExecutor is the common interface of *sql.DB and *sql.Tx used by Models and QueryBuilders
+type dbExecutor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	Prepare(query string) (*sql.Stmt, error)
+	PrepareContext(ctx context.Context, query string) (*sql.Stmt, error)
+}
+
+// TxFunc is the function executed inside a transaction, returning an error rolls the transaction back
+type TxFunc func(tx *Tx) error
+
+// Tx is a database transaction, Models and QueryBuilders bound to a Tx with WithTx run their queries inside it.
+// Nested transactions started with Tx.WithTx are implemented with savepoints.
+type Tx struct {
+	tx      *sql.Tx
+	db      *sql.DB
+	dialect Dialect
+	depth   int
+	done    bool
+}
+
+// BeginTx starts a new transaction on db
+func BeginTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions) (*Tx, error) {
+	if db == nil {
+		return nil, errors.New("cannot begin transaction on nil database")
+	}
+
+	t, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tx{tx: t, db: db, dialect: GetDialect(db)}, nil
+}
+
+// WithTx runs fn inside a transaction on db, the transaction is committed when fn returns nil
+// and rolled back when fn returns an error or panics (the panic is propagated after the rollback).
+func WithTx(ctx context.Context, db *sql.DB, fn TxFunc) (err error) {
+	tx, err := BeginTx(ctx, db, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		if rbErr := tx.Rollback(); rbErr != nil {
+			return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// WithTx runs fn inside a savepoint of the transaction, on error or panic only the work done by fn is rolled back
+func (t *Tx) WithTx(ctx context.Context, fn TxFunc) (err error) {
+	if t == nil || t.tx == nil {
+		return errors.New("cannot start nested transaction on nil transaction")
+	}
+
+	nested := &Tx{tx: t.tx, db: t.db, dialect: t.dialect, depth: t.depth + 1}
+	sp := nested.savepointName()
+
+	if _, err = t.tx.ExecContext(ctx, "SAVEPOINT "+sp); err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			t.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+sp)
+			panic(p)
+		}
+	}()
+
+	if err = fn(nested); err != nil {
+		if _, rbErr := t.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+sp); rbErr != nil {
+			return fmt.Errorf("%w (rollback to savepoint failed: %v)", err, rbErr)
+		}
+		return err
+	}
+
+	_, err = t.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+sp)
+	return err
+}
+
+// Commit commits the transaction, nested transactions are released by WithTx and cannot be committed
+func (t *Tx) Commit() error {
+	if t.depth > 0 {
+		return errors.New("cannot commit a nested transaction")
+	}
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	return t.tx.Commit()
+}
+
+// Rollback aborts the transaction
+func (t *Tx) Rollback() error {
+	if t.depth > 0 {
+		return errors.New("cannot rollback a nested transaction, return an error from its function instead")
+	}
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	return t.tx.Rollback()
+}
+
+// SQLTx returns the underlying *sql.Tx
+func (t *Tx) SQLTx() *sql.Tx {
+	return t.tx
+}
+
+// Exec executes a custom statement inside the transaction, ? placeholders are converted to the dialect style
+func (t *Tx) Exec(ctx context.Context, q string, values ...interface{}) (sql.Result, error) {
+	return t.tx.ExecContext(ctx, rebind(t.dialect, q), values...)
+}
+
+// savepointName returns the savepoint name for the nesting level
+func (t *Tx) savepointName() string {
+	return "gomvc_sp_" + strconv.Itoa(t.depth)
+}
+
+// WithTx returns a copy of the model bound to the transaction
+func (m *Model) WithTx(tx *Tx) *Model {
+	bm := *m
+	bm.tx = tx
+	return &bm
+}
+
+// Tx returns the transaction the model is bound to, nil if none
+func (m *Model) Tx() *Tx {
+	return m.tx
+}
+
+// conn returns the transaction the model is bound to or the model database connection
+func (m *Model) conn() dbExecutor {
+	if m.tx != nil {
+		return m.tx.tx
+	}
+	return m.DB
+}
+
+// inTx runs fn with a copy of the model bound to a new transaction,
+// or to a savepoint when the model is already bound to a transaction
+func (m *Model) inTx(ctx context.Context, fn func(tm *Model) error) error {
+	txFn := func(tx *Tx) error {
+		return fn(m.WithTx(tx))
+	}
+	if m.tx != nil {
+		return m.tx.WithTx(ctx, txFn)
+	}
+	return WithTx(ctx, m.DB, txFn)
+}
+
+// WithTx binds the query builder to a transaction
+func (qb *QueryBuilder) WithTx(tx *Tx) *QueryBuilder {
+	qb.model = qb.model.WithTx(tx)
+	return qb
+}
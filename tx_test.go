@@ -0,0 +1,106 @@
+package gomvc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestWithTxSQLite(t *testing.T) {
+	m := testModel(t)
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+
+	err := WithTx(ctx, m.DB, func(tx *Tx) error {
+		if _, err := m.WithTx(tx).Insert([]SQLField{{FieldName: "name", Value: "kept"}}); err != nil {
+			return err
+		}
+		// A nested transaction is a savepoint, its error rolls back only the nested writes
+		err := tx.WithTx(ctx, func(tx *Tx) error {
+			if _, err := m.WithTx(tx).Insert([]SQLField{{FieldName: "name", Value: "nested"}}); err != nil {
+				return err
+			}
+			return errAbort
+		})
+		if !errors.Is(err, errAbort) {
+			t.Errorf("nested error = %v, want %v", err, errAbort)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = WithTx(ctx, m.DB, func(tx *Tx) error {
+		if _, err := m.WithTx(tx).Insert([]SQLField{{FieldName: "name", Value: "rolled back"}}); err != nil {
+			return err
+		}
+		return errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Errorf("WithTx error = %v, want %v", err, errAbort)
+	}
+
+	if got := carNames(t, m); len(got) != 1 || got[0] != "kept" {
+		t.Errorf("cars = %v, want [kept]", got)
+	}
+}
+
+func TestWithTxPanicRollsBack(t *testing.T) {
+	m := testModel(t)
+
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Errorf("recovered %v, want boom", p)
+			}
+		}()
+		WithTx(context.Background(), m.DB, func(tx *Tx) error {
+			m.WithTx(tx).Insert([]SQLField{{FieldName: "name", Value: "lost"}})
+			panic("boom")
+		})
+	}()
+
+	if got := carNames(t, m); len(got) != 0 {
+		t.Errorf("cars = %v after panic, want none", got)
+	}
+}
+
+func TestBeginTxSQLite(t *testing.T) {
+	m := testModel(t)
+	ctx := context.Background()
+
+	tx, err := BeginTx(ctx, m.DB, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.WithTx(tx).NewQueryBuilder().Where("id", ">", 0).Count(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec(ctx, "INSERT INTO cars (name) VALUES (?)", "ford"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); !errors.Is(err, sql.ErrTxDone) {
+		t.Errorf("Rollback after Commit error = %v, want %v", err, sql.ErrTxDone)
+	}
+
+	err = WithTx(ctx, m.DB, func(tx *Tx) error {
+		return tx.WithTx(ctx, func(nested *Tx) error {
+			if err := nested.Commit(); err == nil {
+				t.Error("Commit of a nested transaction succeeded")
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := carNames(t, m); len(got) != 1 || got[0] != "ford" {
+		t.Errorf("cars = %v, want [ford]", got)
+	}
+}
@@ -0,0 +1,330 @@
+package gomvc
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Column values are converted by column type (DatabaseTypeName of the driver):
+//
+//	TINYINT .. BIGINT, INTEGER, SERIAL   int64 (BIGINT UNSIGNED: uint64)
+//	BIT                                  uint64
+//	BOOL, BOOLEAN                        bool
+//	DECIMAL, NUMERIC                     Decimal
+//	FLOAT, REAL, DOUBLE                  float64
+//	CHAR, VARCHAR, TEXT, ENUM, SET, JSON string
+//	BINARY, VARBINARY, BLOB, GEOMETRY    []byte
+//	DATE, DATETIME, TIMESTAMP, YEAR      time.Time
+//	TIME, TIMETZ                         string (e.g. 15:04:05, -838:59:59)
+//
+// Unknown types keep the driver value ([]byte as string), RegisterTypeConverter adds or replaces a converter.
+
+// TypeConverter converts a driver value (never nil) of a column to the ResultRow value
+type TypeConverter func(ct *sql.ColumnType, val interface{}) (interface{}, error)
+
+var (
+	typeConvertersMu  sync.RWMutex
+	customConverters  = make(map[string]TypeConverter)
+	builtinConverters = make(map[string]TypeConverter)
+	decimalPattern    = regexp.MustCompile(`^[+-]?(\d+(\.\d*)?|\.\d+)([eE][+-]?\d+)?$`)
+	errInvalidDecimal = errors.New("invalid decimal number")
+)
+
+func init() {
+	register := func(fn TypeConverter, names ...string) {
+		for _, n := range names {
+			builtinConverters[n] = fn
+		}
+	}
+
+	register(convertInt, "TINYINT", "SMALLINT", "MEDIUMINT", "INT", "INTEGER", "BIGINT",
+		"INT2", "INT4", "INT8", "SERIAL", "SMALLSERIAL", "BIGSERIAL")
+	register(convertBit, "BIT")
+	register(convertBool, "BOOL", "BOOLEAN")
+	register(convertDecimal, "DECIMAL", "NUMERIC", "DEC", "FIXED")
+	register(convertFloat(32), "FLOAT", "FLOAT4")
+	register(convertFloat(64), "REAL", "DOUBLE", "DOUBLE PRECISION", "FLOAT8")
+	register(convertString, "CHAR", "VARCHAR", "TINYTEXT", "TEXT", "MEDIUMTEXT", "LONGTEXT", "ENUM", "SET", "JSON", "JSONB",
+		"BPCHAR", "CHARACTER", "CHARACTER VARYING", "NCHAR", "NVARCHAR", "CLOB", "UUID")
+	register(convertBytes, "BINARY", "VARBINARY", "TINYBLOB", "BLOB", "MEDIUMBLOB", "LONGBLOB", "BYTEA",
+		"GEOMETRY", "POINT", "LINESTRING", "POLYGON", "MULTIPOINT", "MULTILINESTRING", "MULTIPOLYGON", "GEOMETRYCOLLECTION")
+	register(convertTime("2006-01-02"), "DATE")
+	register(convertTime("2006-01-02 15:04:05"), "DATETIME", "TIMESTAMP", "TIMESTAMPTZ")
+	register(convertClock, "TIME", "TIMETZ")
+	register(convertTime("2006"), "YEAR")
+}
+
+// RegisterTypeConverter sets the converter of a column type, e.g. "GEOMETRY" or "UNSIGNED BIGINT".
+// The type name is matched with the driver type name first and then without UNSIGNED / ZEROFILL,
+// a nil converter restores the built-in conversion.
+func RegisterTypeConverter(typeName string, fn TypeConverter) {
+	typeConvertersMu.Lock()
+	defer typeConvertersMu.Unlock()
+
+	typeName = strings.ToUpper(strings.Join(strings.Fields(typeName), " "))
+	if fn == nil {
+		delete(customConverters, typeName)
+		return
+	}
+	customConverters[typeName] = fn
+}
+
+// typeConverter returns the converter of a column type, nil for unknown types
+func typeConverter(ct *sql.ColumnType) TypeConverter {
+	name := databaseTypeName(ct)
+	base, _ := baseTypeName(name)
+
+	typeConvertersMu.RLock()
+	defer typeConvertersMu.RUnlock()
+
+	if fn, ok := customConverters[name]; ok {
+		return fn
+	}
+	if fn, ok := customConverters[base]; ok {
+		return fn
+	}
+	return builtinConverters[base]
+}
+
+// Construct Filed function
+func constructField(ct *sql.ColumnType, val interface{}) (interface{}, error) {
+	if val == nil {
+		return nil, nil
+	}
+
+	if ct != nil {
+		if fn := typeConverter(ct); fn != nil {
+			return fn(ct, val)
+		}
+	}
+
+	// Unknown or missing type (e.g. expressions like COUNT(*) in SQLite), keep the driver value
+	if b, ok := val.([]byte); ok {
+		return string(b), nil
+	}
+	return val, nil
+}
+
+// databaseTypeName returns the upper case column type name without length, e.g. VARCHAR(255) -> VARCHAR
+func databaseTypeName(ct *sql.ColumnType) string {
+	if ct == nil {
+		return ""
+	}
+	n := strings.ToUpper(strings.TrimSpace(ct.DatabaseTypeName()))
+	if i := strings.Index(n, "("); i > -1 {
+		rest := ""
+		if j := strings.Index(n, ")"); j > i {
+			rest = n[j+1:]
+		}
+		n = n[:i] + " " + rest
+	}
+	return strings.Join(strings.Fields(n), " ")
+}
+
+// baseTypeName removes UNSIGNED / ZEROFILL from a type name, e.g. UNSIGNED INT -> INT
+func baseTypeName(name string) (string, bool) {
+	unsigned := false
+	words := make([]string, 0)
+	for _, w := range strings.Fields(name) {
+		switch w {
+		case "UNSIGNED":
+			unsigned = true
+		case "SIGNED", "ZEROFILL":
+		default:
+			words = append(words, w)
+		}
+	}
+	return strings.Join(words, " "), unsigned
+}
+
+// valueText returns the text of a driver value, numbers are formatted without exponent
+func valueText(val interface{}) string {
+	switch v := val.(type) {
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case Decimal:
+		return string(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case int:
+		return strconv.Itoa(v)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	}
+	return fmt.Sprint(val)
+}
+
+// convertInt returns int64, uint64 for BIGINT UNSIGNED (values above the int64 range)
+func convertInt(ct *sql.ColumnType, val interface{}) (interface{}, error) {
+	if base, unsigned := baseTypeName(databaseTypeName(ct)); unsigned {
+		n, err := strconv.ParseUint(valueText(val), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		if base == "BIGINT" || base == "INT8" {
+			return n, nil
+		}
+		return int64(n), nil
+	}
+	return strconv.ParseInt(valueText(val), 10, 64)
+}
+
+// convertBit returns the bits as uint64, MySQL sends BIT values as big endian bytes
+func convertBit(ct *sql.ColumnType, val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case []byte:
+		if len(v) > 8 {
+			return nil, errors.New("BIT value longer than 64 bits")
+		}
+		var n uint64
+		for _, c := range v {
+			n = n<<8 | uint64(c)
+		}
+		return n, nil
+	case int64:
+		return uint64(v), nil
+	case bool:
+		if v {
+			return uint64(1), nil
+		}
+		return uint64(0), nil
+	}
+	return strconv.ParseUint(valueText(val), 10, 64)
+}
+
+// convertBool returns bool for 1 / 0, t / f and true / false values
+func convertBool(ct *sql.ColumnType, val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case bool:
+		return v, nil
+	case int64:
+		return v != 0, nil
+	}
+	return strconv.ParseBool(valueText(val))
+}
+
+// convertDecimal returns the exact value as Decimal
+func convertDecimal(ct *sql.ColumnType, val interface{}) (interface{}, error) {
+	return NewDecimal(valueText(val))
+}
+
+// convertFloat returns float64 parsed with the precision of the column type
+func convertFloat(bitSize int) TypeConverter {
+	return func(ct *sql.ColumnType, val interface{}) (interface{}, error) {
+		if v, ok := val.(float64); ok {
+			return v, nil
+		}
+		return strconv.ParseFloat(valueText(val), bitSize)
+	}
+}
+
+// convertString returns the value as string
+func convertString(ct *sql.ColumnType, val interface{}) (interface{}, error) {
+	return valueText(val), nil
+}
+
+// convertBytes returns the raw bytes of binary columns
+func convertBytes(ct *sql.ColumnType, val interface{}) (interface{}, error) {
+	switch v := val.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	return []byte(valueText(val)), nil
+}
+
+// convertTime parses text values with layout, values already converted by the driver are kept
+func convertTime(layout string) TypeConverter {
+	return func(ct *sql.ColumnType, val interface{}) (interface{}, error) {
+		if t, ok := val.(time.Time); ok {
+			return t, nil
+		}
+		return time.Parse(layout, valueText(val))
+	}
+}
+
+// convertClock returns TIME values as string, they can be durations out of the time of day range (MySql -838:59:59 to 838:59:59)
+func convertClock(ct *sql.ColumnType, val interface{}) (interface{}, error) {
+	if t, ok := val.(time.Time); ok {
+		layout := "15:04:05.999999"
+		if databaseTypeName(ct) == "TIMETZ" {
+			layout += "Z07:00"
+		}
+		return t.Format(layout), nil
+	}
+	return valueText(val), nil
+}
+
+// Decimal is an exact decimal number as read from DECIMAL / NUMERIC columns, e.g. "1234.50".
+// Use it for money values instead of float64, it is written to the database as text
+// and an empty Decimal is written as NULL.
+type Decimal string
+
+// NewDecimal validates a decimal number
+func NewDecimal(s string) (Decimal, error) {
+	s = strings.TrimSpace(s)
+	if !decimalPattern.MatchString(s) {
+		return "", fmt.Errorf("%w: %q", errInvalidDecimal, s)
+	}
+	return Decimal(s), nil
+}
+
+// String returns the decimal text
+func (d Decimal) String() string {
+	return string(d)
+}
+
+// Float64 returns the value as float64, precision may be lost
+func (d Decimal) Float64() (float64, error) {
+	return strconv.ParseFloat(string(d), 64)
+}
+
+// Rat returns the exact value as big.Rat for calculations, false for an invalid or empty Decimal
+func (d Decimal) Rat() (*big.Rat, bool) {
+	return new(big.Rat).SetString(string(d))
+}
+
+// Value implements driver.Valuer
+func (d Decimal) Value() (driver.Value, error) {
+	if len(d) == 0 {
+		return nil, nil
+	}
+	if _, err := NewDecimal(string(d)); err != nil {
+		return nil, err
+	}
+	return string(d), nil
+}
+
+// Scan implements sql.Scanner
+func (d *Decimal) Scan(src interface{}) error {
+	if src == nil {
+		*d = ""
+		return nil
+	}
+	v, err := NewDecimal(valueText(src))
+	if err != nil {
+		return err
+	}
+	*d = v
+	return nil
+}
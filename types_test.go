@@ -0,0 +1,116 @@
+package gomvc
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTypeConverters(t *testing.T) {
+	tests := []struct {
+		name    string
+		fn      TypeConverter
+		val     interface{}
+		want    interface{}
+		wantErr bool
+	}{
+		{"int bytes", convertInt, []byte("42"), int64(42), false},
+		{"int negative", convertInt, []byte("-7"), int64(-7), false},
+		{"int invalid", convertInt, []byte("4x"), nil, true},
+		{"bit bytes", convertBit, []byte{0x01, 0x02}, uint64(258), false},
+		{"bit int", convertBit, int64(5), uint64(5), false},
+		{"bit bool", convertBit, true, uint64(1), false},
+		{"bit too long", convertBit, make([]byte, 9), nil, true},
+		{"bool int", convertBool, int64(0), false, false},
+		{"bool text", convertBool, []byte("t"), true, false},
+		{"bool invalid", convertBool, []byte("maybe"), nil, true},
+		{"decimal", convertDecimal, []byte("1234.50"), Decimal("1234.50"), false},
+		{"decimal float", convertDecimal, float64(0.1), Decimal("0.1"), false},
+		{"decimal invalid", convertDecimal, []byte("12,5"), nil, true},
+		{"float64", convertFloat(64), []byte("2.5"), float64(2.5), false},
+		{"float64 value", convertFloat(64), float64(1.25), float64(1.25), false},
+		{"float32", convertFloat(32), []byte("0.1"), float64(float32(0.1)), false},
+		{"string bytes", convertString, []byte("ford"), "ford", false},
+		{"string int", convertString, int64(3), "3", false},
+		{"bytes", convertBytes, []byte{0, 1}, []byte{0, 1}, false},
+		{"bytes string", convertBytes, "ab", []byte("ab"), false},
+		{"date", convertTime("2006-01-02"), []byte("2021-03-04"), time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC), false},
+		{"datetime", convertTime("2006-01-02 15:04:05"), []byte("2021-03-04 05:06:07"), time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC), false},
+		{"datetime value", convertTime("2006-01-02"), time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC), time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC), false},
+		{"datetime invalid", convertTime("2006-01-02"), []byte("yesterday"), nil, true},
+		{"time", convertClock, []byte("12:30:00"), "12:30:00", false},
+		{"time out of day range", convertClock, []byte("838:59:59"), "838:59:59", false},
+		{"time negative", convertClock, []byte("-01:00:00"), "-01:00:00", false},
+		{"time value", convertClock, time.Date(0, 1, 1, 8, 15, 0, 500000000, time.UTC), "08:15:00.5", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.fn(nil, tt.val)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConstructFieldUnknownType(t *testing.T) {
+	tests := []struct {
+		val  interface{}
+		want interface{}
+	}{
+		{nil, nil},
+		{[]byte("text"), "text"},
+		{int64(3), int64(3)},
+	}
+
+	for _, tt := range tests {
+		got, err := constructField(nil, tt.val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("constructField(%#v) = %#v, want %#v", tt.val, got, tt.want)
+		}
+	}
+}
+
+func TestBaseTypeName(t *testing.T) {
+	tests := []struct {
+		name     string
+		want     string
+		unsigned bool
+	}{
+		{"INT", "INT", false},
+		{"UNSIGNED BIGINT", "BIGINT", true},
+		{"INT UNSIGNED ZEROFILL", "INT", true},
+		{"DOUBLE PRECISION", "DOUBLE PRECISION", false},
+	}
+
+	for _, tt := range tests {
+		got, unsigned := baseTypeName(tt.name)
+		if got != tt.want || unsigned != tt.unsigned {
+			t.Errorf("baseTypeName(%q) = %q, %v, want %q, %v", tt.name, got, unsigned, tt.want, tt.unsigned)
+		}
+	}
+}
+
+func TestNewDecimal(t *testing.T) {
+	for _, s := range []string{"1", "-1.50", ".5", "1e10", " 2.0 "} {
+		if _, err := NewDecimal(s); err != nil {
+			t.Errorf("NewDecimal(%q) error = %v", s, err)
+		}
+	}
+	for _, s := range []string{"", "1,5", "abc", "1.2.3", "--1"} {
+		if _, err := NewDecimal(s); !errors.Is(err, errInvalidDecimal) {
+			t.Errorf("NewDecimal(%q) error = %v, want %v", s, err, errInvalidDecimal)
+		}
+	}
+}
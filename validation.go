@@ -0,0 +1,332 @@
+package gomvc
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// RuleContext is the field value checked by a Rule
+type RuleContext struct {
+	Model *Model
+	Field string
+	Label string      // Model.Labels[Field], Field when the field has no label
+	Value interface{} // nil for NULL or a field missing on insert
+	ID    string      // primary key of the updated record (Key.String for composite keys), empty on insert
+	Key   Key         // primary key of the updated record, nil on insert
+}
+
+// Rule validates a field value and returns the error message, an empty message when the value is valid.
+// The built-in rules except Required accept empty values (nil or "").
+type Rule func(ctx context.Context, rc *RuleContext) (string, error)
+
+// ValidationErrors are the error messages of the invalid fields
+type ValidationErrors map[string][]string
+
+func (e ValidationErrors) Error() string {
+	fields := make([]string, 0, len(e))
+	for f := range e {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+
+	msgs := make([]string, 0, len(fields))
+	for _, f := range fields {
+		msgs = append(msgs, f+": "+strings.Join(e[f], ", "))
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// Has reports whether the field has errors
+func (e ValidationErrors) Has(field string) bool {
+	return len(e[field]) > 0
+}
+
+// First returns the first error message of the field, empty when the field is valid
+func (e ValidationErrors) First(field string) string {
+	if len(e[field]) == 0 {
+		return ""
+	}
+	return e[field][0]
+}
+
+// AddRules sets the validation rules of a field, replacing the rules derived from the column metadata.
+// AddRules(field) without rules disables the validation of the field.
+func (m *Model) AddRules(field string, rules ...Rule) {
+	if m.rules == nil {
+		m.rules = make(map[string][]Rule)
+	}
+	m.rules[field] = rules
+}
+
+// Rules returns the validation rules of a field: the rules added with AddRules, otherwise the rules derived from
+// the column metadata (Required for NOT NULL columns without default, MaxLength for CHAR / VARCHAR columns,
+// OneOf for ENUM columns)
+func (m *Model) Rules(field string) []Rule {
+	if rules, ok := m.rules[field]; ok {
+		return rules
+	}
+
+	switch field {
+	case m.CreatedAtField, m.UpdatedAtField, m.SoftDeleteField, m.VersionField:
+		return nil
+	}
+
+	col := m.Column(field)
+	if col == nil {
+		return nil
+	}
+
+	var rules []Rule
+	if col.IsRequired() {
+		rules = append(rules, Required())
+	}
+	if col.Length > 0 {
+		rules = append(rules, MaxLength(int(col.Length)))
+	}
+	if len(col.Enum) > 0 {
+		rules = append(rules, OneOf(col.Enum...))
+	}
+	return rules
+}
+
+// Validate checks fields with the model rules, id is the primary key of the updated record (empty on insert).
+// On insert every field with rules is checked (missing fields as NULL), on update only the given fields.
+// The returned ValidationErrors is empty when the fields are valid, error reports a failed rule query.
+func (m *Model) Validate(fields []SQLField, id string) (ValidationErrors, error) {
+	return m.ValidateContext(context.Background(), fields, id)
+}
+
+// ValidateContext is Validate with a context
+func (m *Model) ValidateContext(ctx context.Context, fields []SQLField, id string) (ValidationErrors, error) {
+	var key Key
+	if len(id) > 0 {
+		var err error
+		if key, err = m.idKey(id); err != nil {
+			return nil, err
+		}
+	}
+	return m.ValidateByKeyContext(ctx, fields, key)
+}
+
+// ValidateByKey is Validate for the record of key, a nil key validates an insert
+func (m *Model) ValidateByKey(fields []SQLField, key Key) (ValidationErrors, error) {
+	return m.ValidateByKeyContext(context.Background(), fields, key)
+}
+
+// ValidateByKeyContext is ValidateByKey with a context
+func (m *Model) ValidateByKeyContext(ctx context.Context, fields []SQLField, key Key) (ValidationErrors, error) {
+	values := make(map[string]interface{}, len(fields))
+	names := make([]string, 0, len(m.Fields)+len(fields))
+	for _, f := range fields {
+		if _, ok := values[f.FieldName]; !ok {
+			names = append(names, f.FieldName)
+		}
+		values[f.FieldName] = f.Value
+	}
+
+	id := ""
+	if key != nil {
+		id = m.keyText(key)
+	}
+
+	if key == nil {
+		seen := make(map[string]bool, len(names))
+		for _, n := range names {
+			seen[n] = true
+		}
+		for _, n := range append(append([]string{}, m.Fields...), m.ruleFields()...) {
+			if !seen[n] {
+				seen[n] = true
+				names = append(names, n)
+			}
+		}
+	}
+
+	verrs := ValidationErrors{}
+	for _, n := range names {
+		rc := &RuleContext{Model: m, Field: n, Label: m.fieldLabel(n), Value: values[n], ID: id, Key: key}
+		for _, rule := range m.Rules(n) {
+			msg, err := rule(ctx, rc)
+			if err != nil {
+				return nil, err
+			}
+			if len(msg) > 0 {
+				verrs[n] = append(verrs[n], msg)
+			}
+		}
+	}
+
+	return verrs, nil
+}
+
+// ruleFields returns the fields with rules added by AddRules, sorted
+func (m *Model) ruleFields() []string {
+	out := make([]string, 0, len(m.rules))
+	for f := range m.rules {
+		out = append(out, f)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// fieldLabel returns the label of a field, the field name when it has no label
+func (m *Model) fieldLabel(field string) string {
+	if lb, ok := m.Labels[field]; ok {
+		return lb
+	}
+	return field
+}
+
+// isEmptyValue reports whether a field value is NULL or an empty string
+func isEmptyValue(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	s, ok := v.(string)
+	return ok && len(strings.TrimSpace(s)) == 0
+}
+
+// Required fails for NULL and empty values
+func Required() Rule {
+	return func(ctx context.Context, rc *RuleContext) (string, error) {
+		if isEmptyValue(rc.Value) {
+			return rc.Label + " is required", nil
+		}
+		return "", nil
+	}
+}
+
+// MinLength fails for values shorter than n characters
+func MinLength(n int) Rule {
+	return func(ctx context.Context, rc *RuleContext) (string, error) {
+		if isEmptyValue(rc.Value) {
+			return "", nil
+		}
+		if utf8.RuneCountInString(valueText(rc.Value)) < n {
+			return fmt.Sprintf("%s must be at least %d characters", rc.Label, n), nil
+		}
+		return "", nil
+	}
+}
+
+// MaxLength fails for values longer than n characters
+func MaxLength(n int) Rule {
+	return func(ctx context.Context, rc *RuleContext) (string, error) {
+		if isEmptyValue(rc.Value) {
+			return "", nil
+		}
+		if utf8.RuneCountInString(valueText(rc.Value)) > n {
+			return fmt.Sprintf("%s must be at most %d characters", rc.Label, n), nil
+		}
+		return "", nil
+	}
+}
+
+// Range fails for values that are not numbers between min and max (inclusive)
+func Range(min, max float64) Rule {
+	return func(ctx context.Context, rc *RuleContext) (string, error) {
+		if isEmptyValue(rc.Value) {
+			return "", nil
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(valueText(rc.Value)), 64)
+		if err != nil {
+			return rc.Label + " must be a number", nil
+		}
+		if f < min || f > max {
+			return fmt.Sprintf("%s must be between %v and %v", rc.Label, min, max), nil
+		}
+		return "", nil
+	}
+}
+
+// OneOf fails for values that are not in values
+func OneOf(values ...string) Rule {
+	return func(ctx context.Context, rc *RuleContext) (string, error) {
+		if isEmptyValue(rc.Value) {
+			return "", nil
+		}
+		if FindInSlice(values, valueText(rc.Value)) == -1 {
+			return rc.Label + " must be one of " + strings.Join(values, ", "), nil
+		}
+		return "", nil
+	}
+}
+
+// Match fails for values that do not match re
+func Match(re *regexp.Regexp) Rule {
+	return func(ctx context.Context, rc *RuleContext) (string, error) {
+		if isEmptyValue(rc.Value) {
+			return "", nil
+		}
+		if !re.MatchString(valueText(rc.Value)) {
+			return rc.Label + " has an invalid format", nil
+		}
+		return "", nil
+	}
+}
+
+// Email fails for values that are not a plain email address (user@example.com)
+func Email() Rule {
+	return func(ctx context.Context, rc *RuleContext) (string, error) {
+		if isEmptyValue(rc.Value) {
+			return "", nil
+		}
+		s := valueText(rc.Value)
+		a, err := mail.ParseAddress(s)
+		if err != nil || a.Address != s {
+			return rc.Label + " must be a valid email address", nil
+		}
+		return "", nil
+	}
+}
+
+// Unique fails when another record of the model table has the same value, soft deleted records included
+func Unique() Rule {
+	return func(ctx context.Context, rc *RuleContext) (string, error) {
+		if isEmptyValue(rc.Value) {
+			return "", nil
+		}
+		m := rc.Model
+		n, err := m.NewQueryBuilder().WithTrashed().Where(m.TableName+"."+rc.Field, "=", rc.Value).CountContext(ctx)
+		if err != nil {
+			return "", err
+		}
+		if n > 0 && rc.Key != nil {
+			// The updated record itself does not count
+			self, err := m.NewQueryBuilder().WithTrashed().Where(m.TableName+"."+rc.Field, "=", rc.Value).whereKey(rc.Key).CountContext(ctx)
+			if err != nil {
+				return "", err
+			}
+			n -= self
+		}
+		if n > 0 {
+			return rc.Label + " is already taken", nil
+		}
+		return "", nil
+	}
+}
+
+// Exists fails when no row of table has the value in column, e.g. Exists("brands", "id") for a foreign key
+func Exists(table, column string) Rule {
+	return func(ctx context.Context, rc *RuleContext) (string, error) {
+		if isEmptyValue(rc.Value) {
+			return "", nil
+		}
+		m := rc.Model
+		ref := &Model{DB: m.DB, Dialect: m.Dialect, TableName: table, tx: m.tx}
+		n, err := ref.NewQueryBuilder().Where(table+"."+column, "=", rc.Value).CountContext(ctx)
+		if err != nil {
+			return "", err
+		}
+		if n == 0 {
+			return rc.Label + " does not exist", nil
+		}
+		return "", nil
+	}
+}
@@ -0,0 +1,91 @@
+package gomvc
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  Rule
+		value interface{}
+		want  string
+	}{
+		{"required nil", Required(), nil, "Name is required"},
+		{"required blank", Required(), "  ", "Name is required"},
+		{"required zero", Required(), int64(0), ""},
+		{"min length", MinLength(3), "ab", "Name must be at least 3 characters"},
+		{"min length empty", MinLength(3), "", ""},
+		{"max length runes", MaxLength(3), "αβγ", ""},
+		{"max length", MaxLength(3), "abcd", "Name must be at most 3 characters"},
+		{"range", Range(1, 10), "10", ""},
+		{"range out", Range(1, 10), int64(11), "Name must be between 1 and 10"},
+		{"range text", Range(1, 10), "x", "Name must be a number"},
+		{"one of", OneOf("a", "b"), "b", ""},
+		{"one of missing", OneOf("a", "b"), "c", "Name must be one of a, b"},
+		{"match", Match(regexp.MustCompile(`^[A-Z]{3}$`)), "abc", "Name has an invalid format"},
+		{"email", Email(), "user@example.com", ""},
+		{"email with name", Email(), "User <user@example.com>", "Name must be a valid email address"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.rule(context.Background(), &RuleContext{Field: "name", Label: "Name", Value: tt.value})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("message = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSQLite(t *testing.T) {
+	m := testModel(t)
+	insertCars(t, m, "ford", "bmw")
+	m.Labels = map[string]string{"name": "Name"}
+
+	// rules derived from the columns: name is NOT NULL without default and VARCHAR(50)
+	verrs, err := m.Validate([]SQLField{{FieldName: "price", Value: "1"}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (ValidationErrors{"name": {"Name is required"}}); !reflect.DeepEqual(verrs, want) {
+		t.Errorf("insert errors = %v, want %v", verrs, want)
+	}
+	verrs, err = m.Validate([]SQLField{{FieldName: "name", Value: strings.Repeat("x", 51)}}, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verrs.First("name") != "Name must be at most 50 characters" {
+		t.Errorf("update errors = %v, want the max length error", verrs)
+	}
+	// updates check only the given fields
+	if verrs, err = m.Validate([]SQLField{{FieldName: "price", Value: "2"}}, "1"); err != nil || len(verrs) != 0 {
+		t.Errorf("update of price = %v, %v, want no errors", verrs, err)
+	}
+
+	m.AddRules("name", Required(), Unique())
+	m.AddRules("price", Exists("cars", "id"))
+	verrs, err = m.Validate([]SQLField{{FieldName: "name", Value: "bmw"}, {FieldName: "price", Value: 9}}, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !verrs.Has("name") || verrs.First("price") != "price does not exist" {
+		t.Errorf("errors = %v, want name taken and price does not exist", verrs)
+	}
+	// the updated record does not conflict with itself
+	if verrs, err = m.Validate([]SQLField{{FieldName: "name", Value: "bmw"}, {FieldName: "price", Value: 1}}, "2"); err != nil || len(verrs) != 0 {
+		t.Errorf("update of bmw itself = %v, %v, want no errors", verrs, err)
+	}
+
+	m.AddRules("name")
+	if verrs, err = m.Validate(nil, ""); err != nil || len(verrs) != 0 {
+		t.Errorf("errors without name rules = %v, %v, want none", verrs, err)
+	}
+}
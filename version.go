@@ -0,0 +1,79 @@
+package gomvc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrStaleRecord is returned by Update when the record version changed since it was read, see Model.VersionField
+var ErrStaleRecord = errors.New("record was changed by someone else")
+
+// StaleRecordMessage is the flash error shown by the update action when the record version changed
+var StaleRecordMessage = "The record was changed by someone else, please reload it and try again."
+
+// StaleRecordError is the optimistic locking conflict of a record, errors.Is(err, ErrStaleRecord) reports it
+type StaleRecordError struct {
+	Table   string
+	ID      string
+	Version interface{}
+}
+
+func (e *StaleRecordError) Error() string {
+	return fmt.Sprintf("%v: table %s, id %s, version %v", ErrStaleRecord, e.Table, e.ID, e.Version)
+}
+
+func (e *StaleRecordError) Unwrap() error {
+	return ErrStaleRecord
+}
+
+// sqlIncrement is a SQLField value that adds to the current column value: SET col = col + n
+type sqlIncrement int64
+
+// withVersion replaces the VersionField value of update fields with an increment,
+// the replaced value is the expected version (check is false when the fields have no version value)
+func (m *Model) withVersion(fields []SQLField) (out []SQLField, version interface{}, check bool) {
+	if len(m.VersionField) == 0 {
+		return fields, nil, false
+	}
+
+	out = make([]SQLField, 0, len(fields)+1)
+	for _, f := range fields {
+		if f.FieldName == m.VersionField {
+			if f.Value != nil && f.Value != "" {
+				version, check = f.Value, true
+			}
+			continue
+		}
+		out = append(out, f)
+	}
+	out = append(out, SQLField{FieldName: m.VersionField, Value: sqlIncrement(1)})
+
+	return out, version, check
+}
+
+// withInsertVersion adds the VersionField with version 1 to insert fields without version
+func (m *Model) withInsertVersion(fields []SQLField) []SQLField {
+	if len(m.VersionField) == 0 {
+		return fields
+	}
+	for _, f := range fields {
+		if f.FieldName == m.VersionField {
+			return fields
+		}
+	}
+	return append(append([]SQLField{}, fields...), SQLField{FieldName: m.VersionField, Value: 1})
+}
+
+// staleRecord returns the error of an update that matched no row with the expected version,
+// ErrRecordNotFound when the record does not exist
+func (m *Model) staleRecord(ctx context.Context, key Key, version interface{}) error {
+	n, err := m.NewQueryBuilder().WithTrashed().whereKey(key).CountContext(ctx)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrRecordNotFound
+	}
+	return &StaleRecordError{Table: m.TableName, ID: m.keyText(key), Version: version}
+}
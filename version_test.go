@@ -0,0 +1,52 @@
+package gomvc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestVersionSQLite(t *testing.T) {
+	m := testModel(t)
+	m.VersionField = "version"
+	insertCars(t, m, "ford")
+
+	version := func() int64 {
+		r, err := m.FindByKey(Key{"id": 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r.Int("version")
+	}
+	if v := version(); v != 1 {
+		t.Fatalf("version after insert = %d, want 1", v)
+	}
+
+	if _, err := m.Update([]SQLField{{FieldName: "name", Value: "Ford"}, {FieldName: "version", Value: "1"}}, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if v := version(); v != 2 {
+		t.Fatalf("version after update = %d, want 2", v)
+	}
+
+	// An update with the version read before the last update is a conflict
+	_, err := m.Update([]SQLField{{FieldName: "name", Value: "FORD"}, {FieldName: "version", Value: "1"}}, "1")
+	var se *StaleRecordError
+	if !errors.Is(err, ErrStaleRecord) || !errors.As(err, &se) || se.ID != "1" {
+		t.Errorf("stale update error = %v, want StaleRecordError of id 1", err)
+	}
+	if got := carNames(t, m); got[0] != "Ford" {
+		t.Errorf("name after stale update = %q, want Ford", got[0])
+	}
+
+	// Updates without version are not checked
+	if _, err := m.Update([]SQLField{{FieldName: "name", Value: "FORD"}}, "1"); err != nil {
+		t.Fatal(err)
+	}
+	if v := version(); v != 3 {
+		t.Errorf("version after unchecked update = %d, want 3", v)
+	}
+
+	if _, err := m.Update([]SQLField{{FieldName: "name", Value: "x"}, {FieldName: "version", Value: "3"}}, "9"); !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("update of a missing record error = %v, want %v", err, ErrRecordNotFound)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
 	"strings"
 	"text/template"
 )
@@ -15,12 +16,23 @@ type TemplateData struct {
 	AuthExpired  bool
 	Model        Model
 	Result       []ResultRow
+	Pagination   *Pagination // set when the view action paginates (?page=&per_page=), nil otherwise
 	URLParams    map[string][]interface{}
 	CustomValues map[string][]interface{}
 	CSRFToken    string
 	Flash        string
 	Warning      string
 	Error        string
+	FormErrors   ValidationErrors // per field errors when a create / update form failed validation
+	OldInput     url.Values       // the posted form values when a create / update form failed validation, not escaped (use .Old)
+}
+
+// Old returns the HTML escaped posted value of a form field, empty when the form did not fail validation.
+// The create / update actions skip fields posted empty: a form sets a field to NULL or to an empty string with the
+// hidden inputs _null and _empty (FormNullKey, FormEmptyKey) listing the field, e.g.
+// <input type="hidden" name="_null" value="discount">. OldInput keeps them, {{index .OldInput "_null"}} are the listed fields.
+func (td TemplateData) Old(field string) string {
+	return template.HTMLEscapeString(td.OldInput.Get(field))
 }
 
 // ====================================================================== Template ready functions ======================================================================